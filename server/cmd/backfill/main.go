@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/crawler"
+	"wechat2rss/internal/database"
+	"wechat2rss/internal/search"
+)
+
+// backfill derives plain-text and Markdown renditions for articles stored
+// before the crawler produced them.
+func main() {
+	all := flag.Bool("all", false, "re-render every article, not only those missing renditions")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("load config: %v", err)
+	}
+
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatalf("connect db: %v", err)
+	}
+
+	if err := database.AutoMigrate(db); err != nil {
+		log.Fatalf("auto migrate: %v", err)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	updated, err := crawler.BackfillRenditions(ctx, db, search.New(db, cfg.SearchConfig), *all)
+	if err != nil {
+		log.Fatalf("backfill stopped after %d articles: %v", updated, err)
+	}
+	log.Printf("backfill finished: %d articles updated", updated)
+}
@@ -8,10 +8,14 @@ import (
 	"syscall"
 	"time"
 
+	"wechat2rss/internal/alert"
 	"wechat2rss/internal/config"
 	"wechat2rss/internal/crawler"
 	"wechat2rss/internal/database"
+	"wechat2rss/internal/feed"
 	httpserver "wechat2rss/internal/http"
+	"wechat2rss/internal/search"
+	"wechat2rss/internal/service"
 	"wechat2rss/internal/wechat"
 )
 
@@ -35,14 +39,26 @@ func main() {
 		log.Fatalf("wechat manager init: %v", err)
 	}
 
-	server := httpserver.New(cfg, db, wechatManager)
-	manager := crawler.NewManager(cfg, db)
+	searchEngine := search.New(db, cfg.SearchConfig)
+	feedCache := feed.NewCache(time.Duration(cfg.FeedCacheTTL) * time.Second)
+	hooks := &crawler.Hooks{}
+	hooks.OnArticlesChanged(feedCache.InvalidateAccount)
+
+	server := httpserver.New(cfg, db, wechatManager, searchEngine, feedCache)
+	hooks.OnArticlesChanged(server.PublishArticles)
+	manager := crawler.NewManager(cfg, db, searchEngine, hooks)
+	rechecker := crawler.NewRechecker(cfg, db, searchEngine, hooks)
+	alerts := alert.NewEngine(cfg, db)
 
 	crawlerCtx, crawlerCancel := context.WithCancel(context.Background())
 	defer crawlerCancel()
 
 	go manager.Start(crawlerCtx)
+	go rechecker.Start(crawlerCtx)
+	go alerts.Start(crawlerCtx)
+	go searchEngine.IndexMissing(crawlerCtx)
 	go wechatManager.StartPolling(crawlerCtx)
+	go service.PruneAuditEvents(crawlerCtx, db, cfg.AuditRetention)
 
 	go func() {
 		if err := server.Run(); err != nil {
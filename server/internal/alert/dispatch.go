@@ -0,0 +1,148 @@
+package alert
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/models"
+)
+
+// dispatcher routes alert events to the enabled channels. Each channel
+// gets at most ALERT_NOTIFY_RATE deliveries per hour.
+type dispatcher struct {
+	cfg *config.Config
+	db  *gorm.DB
+
+	mu   sync.Mutex
+	sent map[uint][]time.Time
+}
+
+func newDispatcher(cfg *config.Config, db *gorm.DB) *dispatcher {
+	return &dispatcher{cfg: cfg, db: db, sent: make(map[uint][]time.Time)}
+}
+
+// dispatch announces open alerts nobody has been told about yet and the
+// resolution of notified alerts. An alert counts as notified once one
+// channel accepted it; until then it is retried on every evaluation, so
+// alerts held back by the rate limit or a failing channel are delayed
+// rather than lost. Resolutions are sent once.
+func (d *dispatcher) dispatch(ctx context.Context, resolved []models.Alert) {
+	var channels []models.AlertChannel
+	if err := d.db.WithContext(ctx).Where("enabled = ?", true).Order("id").Find(&channels).Error; err != nil {
+		log.Printf("alert channels error: %v", err)
+		return
+	}
+	if len(channels) == 0 {
+		return
+	}
+
+	var pending []models.Alert
+	if err := d.db.WithContext(ctx).
+		Where("status = ? AND notified_at IS NULL", models.AlertStatusOpen).
+		Order("id").Find(&pending).Error; err != nil {
+		log.Printf("pending alerts error: %v", err)
+		return
+	}
+	for i := range pending {
+		a := &pending[i]
+		delivered := false
+		for j := range channels {
+			if Routes(&channels[j], a) && d.send(ctx, &channels[j], EventRaised, a) {
+				delivered = true
+			}
+		}
+		if delivered {
+			if err := d.db.Model(a).Update("notified_at", time.Now()).Error; err != nil {
+				log.Printf("alert %d notified_at error: %v", a.ID, err)
+			}
+		}
+	}
+
+	for i := range resolved {
+		a := &resolved[i]
+		if a.NotifiedAt == nil {
+			continue
+		}
+		for j := range channels {
+			if channels[j].NotifyResolved && Routes(&channels[j], a) {
+				d.send(ctx, &channels[j], EventResolved, a)
+			}
+		}
+	}
+}
+
+// send delivers one event and records the outcome on the channel.
+func (d *dispatcher) send(ctx context.Context, ch *models.AlertChannel, event string, a *models.Alert) bool {
+	if !d.allow(ch.ID) {
+		log.Printf("alert channel %d rate limited, alert %d %s deferred", ch.ID, a.ID, event)
+		return false
+	}
+	err := Send(ctx, d.cfg, ch, Notification{Event: event, Alert: a, URL: d.cfg.PublicBaseURL})
+	updates := map[string]any{"last_error": ""}
+	if err != nil {
+		log.Printf("alert channel %d notify alert %d error: %v", ch.ID, a.ID, err)
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_sent_at"] = time.Now()
+	}
+	if err := d.db.Model(ch).Updates(updates).Error; err != nil {
+		log.Printf("alert channel %d update error: %v", ch.ID, err)
+	}
+	return err == nil
+}
+
+// allow counts a delivery attempt against the channel's hourly budget.
+// Failed attempts count too, so a broken endpoint is not hammered.
+func (d *dispatcher) allow(channelID uint) bool {
+	if d.cfg.AlertNotifyRate <= 0 {
+		return true
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	cutoff := time.Now().Add(-time.Hour)
+	recent := d.sent[channelID][:0]
+	for _, t := range d.sent[channelID] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) >= d.cfg.AlertNotifyRate {
+		d.sent[channelID] = recent
+		return false
+	}
+	d.sent[channelID] = append(recent, time.Now())
+	return true
+}
+
+// Send delivers a notification through ch.
+func Send(ctx context.Context, cfg *config.Config, ch *models.AlertChannel, n Notification) error {
+	notifier, err := NewNotifier(cfg, ch)
+	if err != nil {
+		return err
+	}
+	return notifier.Notify(ctx, n)
+}
+
+// SendTest sends a sample alert through ch so its configuration can be
+// checked.
+func SendTest(ctx context.Context, cfg *config.Config, ch *models.AlertChannel) error {
+	now := time.Now()
+	return Send(ctx, cfg, ch, Notification{
+		Event: EventTest,
+		Alert: &models.Alert{
+			Type:       models.AlertSessionExpired,
+			Status:     models.AlertStatusOpen,
+			Severity:   models.AlertSeverityCritical,
+			Message:    "这是一条测试通知，告警渠道「" + ch.Name + "」配置正确",
+			LastSeenAt: now,
+			CreatedAt:  now,
+		},
+		URL: cfg.PublicBaseURL,
+	})
+}
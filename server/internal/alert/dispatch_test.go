@@ -0,0 +1,126 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/database"
+	"wechat2rss/internal/models"
+)
+
+// testDB returns a transaction on TEST_DATABASE_URL that is rolled back
+// when the test ends.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	db, err := database.Connect(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	if err := tx.AutoMigrate(&models.Alert{}, &models.AlertChannel{}); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestDispatchNotifiedAt(t *testing.T) {
+	db := testDB(t)
+
+	ok, okRequests := standIn(t, http.StatusOK, "")
+	broken, brokenRequests := standIn(t, http.StatusInternalServerError, "")
+	// events lists the "event:type" pairs the working endpoint received
+	events := func() []string {
+		var events []string
+		for _, req := range okRequests() {
+			var body struct {
+				Event string         `json:"event"`
+				Alert map[string]any `json:"alert"`
+			}
+			if err := json.Unmarshal(req.body, &body); err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, body.Event+":"+body.Alert["type"].(string))
+		}
+		return events
+	}
+
+	channels := []models.AlertChannel{
+		{Name: "broken", Kind: models.AlertChannelWebhook, Target: broken.URL, Enabled: true},
+		{Name: "tasks", Kind: models.AlertChannelWebhook, Target: ok.URL, Types: models.AlertTaskFailures, NotifyResolved: true, Enabled: true},
+		{Name: "disabled", Kind: models.AlertChannelWebhook, Target: ok.URL},
+	}
+	if err := db.Create(&channels).Error; err != nil {
+		t.Fatal(err)
+	}
+	// Create skips zero-value bools that have no default, so disable
+	// explicitly
+	db.Model(&channels[2]).Update("enabled", false)
+
+	now := time.Now()
+	alerts := []models.Alert{
+		{Type: models.AlertSessionExpired, Key: "session_expired:session:1", Status: models.AlertStatusOpen, Severity: models.AlertSeverityCritical, LastSeenAt: now},
+		{Type: models.AlertTaskFailures, Key: "task_failures:account:1", Status: models.AlertStatusOpen, Severity: models.AlertSeverityWarning, LastSeenAt: now},
+	}
+	if err := db.Create(&alerts).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	d := newDispatcher(&config.Config{AlertNotifyRate: 1}, db)
+	d.dispatch(context.Background(), nil)
+
+	reload := func(a *models.Alert) *models.Alert {
+		var got models.Alert
+		if err := db.First(&got, a.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return &got
+	}
+	if got := reload(&alerts[0]); got.NotifiedAt != nil {
+		t.Error("alert only routed to a failing channel marked notified")
+	}
+	if got := reload(&alerts[1]); got.NotifiedAt == nil {
+		t.Error("delivered alert not marked notified")
+	}
+	var brokenCh models.AlertChannel
+	db.First(&brokenCh, channels[0].ID)
+	if brokenCh.LastError == "" || brokenCh.LastSentAt != nil {
+		t.Errorf("failing channel state: last_error=%q last_sent_at=%v", brokenCh.LastError, brokenCh.LastSentAt)
+	}
+	if got := events(); len(got) != 1 || got[0] != "raised:"+models.AlertTaskFailures {
+		t.Fatalf("events after first dispatch %v", got)
+	}
+
+	// the failing channel has spent its hourly budget, so the pending
+	// alert is held back instead of retried
+	d.dispatch(context.Background(), nil)
+	if n := len(brokenRequests()); n != 1 {
+		t.Errorf("rate-limited channel contacted %d times", n)
+	}
+	if got := reload(&alerts[0]); got.NotifiedAt != nil {
+		t.Error("deferred alert marked notified")
+	}
+
+	// resolutions go out only for alerts that were announced
+	resolved := []models.Alert{*reload(&alerts[0]), *reload(&alerts[1])}
+	for i := range resolved {
+		resolved[i].Status = models.AlertStatusResolved
+		resolved[i].ResolvedAt = &now
+	}
+	d.sent = make(map[uint][]time.Time)
+	d.dispatch(context.Background(), resolved)
+	if got := events(); len(got) != 2 || got[1] != "resolved:"+models.AlertTaskFailures {
+		t.Errorf("events after resolution %v", got)
+	}
+}
@@ -0,0 +1,145 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"wechat2rss/internal/config"
+)
+
+// emailNotifier sends plain-text mail through the SMTP_* server. Without
+// SMTP_TLS the connection is upgraded with STARTTLS when the server offers
+// it; credentials are only sent over TLS, except to localhost.
+type emailNotifier struct {
+	addr        string
+	host        string
+	username    string
+	password    string
+	from        *mail.Address
+	to          []*mail.Address
+	implicitTLS bool
+}
+
+func newEmailNotifier(cfg *config.Config, recipients string) (*emailNotifier, error) {
+	from, err := mail.ParseAddress(cfg.SMTPFrom)
+	if err != nil {
+		return nil, fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+	to, err := parseRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	return &emailNotifier{
+		addr:        net.JoinHostPort(cfg.SMTPHost, strconv.Itoa(cfg.SMTPPort)),
+		host:        cfg.SMTPHost,
+		username:    cfg.SMTPUsername,
+		password:    cfg.SMTPPassword,
+		from:        from,
+		to:          to,
+		implicitTLS: cfg.SMTPImplicitTLS,
+	}, nil
+}
+
+func parseRecipients(list string) ([]*mail.Address, error) {
+	to, err := mail.ParseAddressList(list)
+	if err != nil {
+		return nil, err
+	}
+	if len(to) == 0 {
+		return nil, errors.New("no recipients")
+	}
+	return to, nil
+}
+
+func (e *emailNotifier) Notify(ctx context.Context, n Notification) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	var conn net.Conn
+	var err error
+	if e.implicitTLS {
+		dialer := &tls.Dialer{Config: &tls.Config{ServerName: e.host}}
+		conn, err = dialer.DialContext(ctx, "tcp", e.addr)
+	} else {
+		var dialer net.Dialer
+		conn, err = dialer.DialContext(ctx, "tcp", e.addr)
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	_ = conn.SetDeadline(deadline)
+
+	client, err := smtp.NewClient(conn, e.host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if !e.implicitTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(&tls.Config{ServerName: e.host}); err != nil {
+				return err
+			}
+		}
+	}
+	if e.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", e.username, e.password, e.host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(e.from.Address); err != nil {
+		return err
+	}
+	for _, rcpt := range e.to {
+		if err := client.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(e.message(n)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// message renders the mail with a base64 UTF-8 body.
+func (e *emailNotifier) message(n Notification) []byte {
+	to := make([]string, 0, len(e.to))
+	for _, rcpt := range e.to {
+		to = append(to, rcpt.String())
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", e.from.String())
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("utf-8", "Wechat2RSS "+n.title()))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	body := base64.StdEncoding.EncodeToString([]byte(n.text()))
+	for len(body) > 76 {
+		b.WriteString(body[:76] + "\r\n")
+		body = body[76:]
+	}
+	b.WriteString(body + "\r\n")
+	return b.Bytes()
+}
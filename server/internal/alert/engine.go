@@ -0,0 +1,152 @@
+// Package alert watches crawling for problems that need a human, such as
+// an expired mp login, and keeps one deduplicated alert per problem that
+// resolves itself once the condition clears.
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/models"
+)
+
+// finding is a condition currently observed by a rule.
+type finding struct {
+	key       string
+	severity  string
+	message   string
+	accountID *uint
+	sessionID *uint
+	payload   map[string]any
+}
+
+// rule evaluates one alert type.
+type rule struct {
+	typ   string
+	check func(ctx context.Context) ([]finding, error)
+}
+
+// Engine periodically evaluates the alert rules and notifies the alert
+// channels of changes.
+type Engine struct {
+	cfg      *config.Config
+	db       *gorm.DB
+	notifier *dispatcher
+}
+
+// NewEngine returns an engine configured by the ALERT_* settings.
+func NewEngine(cfg *config.Config, db *gorm.DB) *Engine {
+	return &Engine{cfg: cfg, db: db, notifier: newDispatcher(cfg, db)}
+}
+
+// Start evaluates the rules on startup and then every ALERT_CHECK_INTERVAL
+// minutes until ctx is done. An interval of zero disables alerting.
+func (e *Engine) Start(ctx context.Context) {
+	if e.cfg.AlertInterval <= 0 {
+		return
+	}
+	// check right away so problems present at startup are reported
+	// without waiting a full interval
+	e.Evaluate(ctx)
+	ticker := time.NewTicker(time.Duration(e.cfg.AlertInterval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.Evaluate(ctx)
+		}
+	}
+}
+
+// Evaluate runs every rule once and reconciles the stored alerts with its
+// findings, then sends notifications. A failing rule leaves its alerts
+// untouched.
+func (e *Engine) Evaluate(ctx context.Context) {
+	var resolved []models.Alert
+	for _, r := range e.rules() {
+		findings, err := r.check(ctx)
+		if err != nil {
+			log.Printf("alert rule %s error: %v", r.typ, err)
+			continue
+		}
+		cleared, err := e.reconcile(r.typ, findings)
+		if err != nil {
+			log.Printf("alert reconcile %s error: %v", r.typ, err)
+		}
+		resolved = append(resolved, cleared...)
+	}
+	e.notifier.dispatch(ctx, resolved)
+}
+
+// reconcile opens alerts for new findings, refreshes those still firing
+// and resolves unresolved alerts of typ that were not found again.
+// Acknowledged alerts stay acknowledged while they keep firing. It returns
+// the alerts it resolved.
+func (e *Engine) reconcile(typ string, findings []finding) ([]models.Alert, error) {
+	var active []models.Alert
+	if err := e.db.Where("type = ? AND status <> ?", typ, models.AlertStatusResolved).Find(&active).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*models.Alert, len(active))
+	for i := range active {
+		byKey[active[i].Key] = &active[i]
+	}
+
+	now := time.Now()
+	for _, f := range findings {
+		key := typ + ":" + f.key
+		payload, err := json.Marshal(f.payload)
+		if err != nil {
+			return nil, err
+		}
+		if existing, ok := byKey[key]; ok {
+			delete(byKey, key)
+			if err := e.db.Model(existing).Updates(map[string]any{
+				"severity":     f.severity,
+				"message":      f.message,
+				"payload":      string(payload),
+				"last_seen_at": now,
+			}).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		alert := models.Alert{
+			Type:       typ,
+			Key:        key,
+			Status:     models.AlertStatusOpen,
+			Severity:   f.severity,
+			Message:    f.message,
+			AccountID:  f.accountID,
+			SessionID:  f.sessionID,
+			Payload:    string(payload),
+			LastSeenAt: now,
+		}
+		if err := e.db.Create(&alert).Error; err != nil {
+			return nil, err
+		}
+		log.Printf("alert %d raised: %s", alert.ID, alert.Message)
+	}
+
+	var resolved []models.Alert
+	for _, alert := range byKey {
+		if err := e.db.Model(alert).Updates(map[string]any{
+			"status":      models.AlertStatusResolved,
+			"resolved_at": now,
+		}).Error; err != nil {
+			return resolved, err
+		}
+		log.Printf("alert %d resolved: %s", alert.ID, alert.Message)
+		alert.Status = models.AlertStatusResolved
+		alert.ResolvedAt = &now
+		resolved = append(resolved, *alert)
+	}
+	return resolved, nil
+}
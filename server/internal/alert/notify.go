@@ -0,0 +1,157 @@
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/models"
+)
+
+// Events announced to notifiers.
+const (
+	EventRaised   = "raised"
+	EventResolved = "resolved"
+	EventTest     = "test"
+)
+
+// Notification is one alert event to deliver.
+type Notification struct {
+	Event string
+	Alert *models.Alert
+	// URL points at the console when PUBLIC_BASE_URL is set.
+	URL string
+}
+
+// Notifier delivers notifications to one destination.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+var notifyClient = &http.Client{Timeout: 10 * time.Second}
+
+// NewNotifier returns the notifier for a channel.
+func NewNotifier(cfg *config.Config, ch *models.AlertChannel) (Notifier, error) {
+	switch ch.Kind {
+	case models.AlertChannelWebhook:
+		return &webhookNotifier{url: ch.Target, secret: ch.Secret, client: notifyClient}, nil
+	case models.AlertChannelEmail:
+		if cfg.SMTPHost == "" {
+			return nil, fmt.Errorf("SMTP_HOST is not configured")
+		}
+		return newEmailNotifier(cfg, ch.Target)
+	case models.AlertChannelWeCom, models.AlertChannelFeishu, models.AlertChannelDingTalk, models.AlertChannelSlack:
+		return &chatNotifier{kind: ch.Kind, url: ch.Target, secret: ch.Secret, client: notifyClient}, nil
+	}
+	return nil, fmt.Errorf("unknown channel kind %q", ch.Kind)
+}
+
+// ValidateChannel checks the kind and target of a channel before it is
+// saved.
+func ValidateChannel(cfg *config.Config, ch *models.AlertChannel) error {
+	if ch.Kind == models.AlertChannelEmail {
+		if _, err := parseRecipients(ch.Target); err != nil {
+			return fmt.Errorf("invalid recipients: %w", err)
+		}
+	} else if u, err := url.Parse(ch.Target); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("target must be an http(s) URL")
+	}
+	_, err := NewNotifier(cfg, ch)
+	return err
+}
+
+// Routes reports whether ch wants notifications about a.
+func Routes(ch *models.AlertChannel, a *models.Alert) bool {
+	if ch.MinSeverity == models.AlertSeverityCritical && a.Severity != models.AlertSeverityCritical {
+		return false
+	}
+	if ch.Types == "" {
+		return true
+	}
+	for _, typ := range strings.Split(ch.Types, ",") {
+		if strings.TrimSpace(typ) == a.Type {
+			return true
+		}
+	}
+	return false
+}
+
+// Types lists the alert types channels can subscribe to.
+var Types = []string{
+	models.AlertSessionExpired,
+	models.AlertTaskFailures,
+	models.AlertStaleAccount,
+	models.AlertFreqControl,
+	models.AlertCrawlerStall,
+}
+
+// title is the one-line summary used as email subject and chat heading.
+func (n Notification) title() string {
+	switch n.Event {
+	case EventResolved:
+		return "[已恢复] " + n.Alert.Message
+	case EventTest:
+		return "[测试] " + n.Alert.Message
+	}
+	if n.Alert.Severity == models.AlertSeverityCritical {
+		return "[严重] " + n.Alert.Message
+	}
+	return "[告警] " + n.Alert.Message
+}
+
+// text renders the notification as plain text for chat robots and email.
+func (n Notification) text() string {
+	var b strings.Builder
+	b.WriteString("Wechat2RSS " + n.title() + "\n")
+	fmt.Fprintf(&b, "类型：%s\n", n.Alert.Type)
+	if n.Alert.ID != 0 {
+		fmt.Fprintf(&b, "告警 ID：%d\n", n.Alert.ID)
+	}
+	fmt.Fprintf(&b, "首次发现：%s\n", n.Alert.CreatedAt.Format(time.DateTime))
+	if n.Alert.ResolvedAt != nil {
+		fmt.Fprintf(&b, "恢复时间：%s\n", n.Alert.ResolvedAt.Format(time.DateTime))
+	}
+	if n.URL != "" {
+		fmt.Fprintf(&b, "控制台：%s\n", n.URL)
+	}
+	return b.String()
+}
+
+// alertPayload is the JSON form of an alert sent to generic webhooks.
+type alertPayload struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	Status     string          `json:"status"`
+	Severity   string          `json:"severity"`
+	Message    string          `json:"message"`
+	AccountID  *uint           `json:"account_id"`
+	SessionID  *uint           `json:"session_id"`
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastSeenAt time.Time       `json:"last_seen_at"`
+	ResolvedAt *time.Time      `json:"resolved_at"`
+}
+
+func toAlertPayload(a *models.Alert) alertPayload {
+	p := alertPayload{
+		ID:         a.ID,
+		Type:       a.Type,
+		Status:     a.Status,
+		Severity:   a.Severity,
+		Message:    a.Message,
+		AccountID:  a.AccountID,
+		SessionID:  a.SessionID,
+		CreatedAt:  a.CreatedAt,
+		LastSeenAt: a.LastSeenAt,
+		ResolvedAt: a.ResolvedAt,
+	}
+	if a.Payload != "" {
+		p.Payload = json.RawMessage(a.Payload)
+	}
+	return p
+}
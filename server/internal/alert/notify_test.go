@@ -0,0 +1,359 @@
+package alert
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/models"
+)
+
+// request is one call captured by the HTTP stand-in.
+type request struct {
+	url    string
+	header http.Header
+	body   []byte
+}
+
+// standIn is a local webhook endpoint answering every request with status
+// and reply.
+func standIn(t *testing.T, status int, reply string) (*httptest.Server, func() []request) {
+	t.Helper()
+	var (
+		mu   sync.Mutex
+		reqs []request
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		reqs = append(reqs, request{url: r.URL.String(), header: r.Header.Clone(), body: body})
+		mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []request {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]request(nil), reqs...)
+	}
+}
+
+func testAlert() *models.Alert {
+	created := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	return &models.Alert{
+		ID:         7,
+		Type:       models.AlertSessionExpired,
+		Key:        "session_expired:session:3",
+		Status:     models.AlertStatusOpen,
+		Severity:   models.AlertSeverityCritical,
+		Message:    "公众号后台会话 #3 已失效",
+		Payload:    `{"session_id":3}`,
+		LastSeenAt: created,
+		CreatedAt:  created,
+	}
+}
+
+func TestWebhookSignature(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, "ok")
+	ch := &models.AlertChannel{Kind: models.AlertChannelWebhook, Target: srv.URL + "/hook", Secret: "s3cret"}
+	n := Notification{Event: EventRaised, Alert: testAlert(), URL: "https://rss.example.com"}
+	if err := Send(context.Background(), &config.Config{}, ch, n); err != nil {
+		t.Fatal(err)
+	}
+
+	reqs := requests()
+	if len(reqs) != 1 {
+		t.Fatalf("got %d requests", len(reqs))
+	}
+	req := reqs[0]
+	if got := req.header.Get("X-Wechat2RSS-Event"); got != EventRaised {
+		t.Errorf("event header = %q", got)
+	}
+	ts := req.header.Get("X-Wechat2RSS-Timestamp")
+	if _, err := strconv.ParseInt(ts, 10, 64); err != nil {
+		t.Fatalf("timestamp header = %q", ts)
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(ts + "."))
+	mac.Write(req.body)
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); req.header.Get("X-Wechat2RSS-Signature") != want {
+		t.Errorf("signature = %q, want %q", req.header.Get("X-Wechat2RSS-Signature"), want)
+	}
+
+	var body struct {
+		Event string         `json:"event"`
+		URL   string         `json:"url"`
+		Alert map[string]any `json:"alert"`
+	}
+	if err := json.Unmarshal(req.body, &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Event != EventRaised || body.URL != n.URL || body.Alert["id"] != float64(7) ||
+		body.Alert["payload"].(map[string]any)["session_id"] != float64(3) {
+		t.Errorf("unexpected body %s", req.body)
+	}
+}
+
+func TestWebhookWithoutSecret(t *testing.T) {
+	srv, requests := standIn(t, http.StatusOK, "")
+	ch := &models.AlertChannel{Kind: models.AlertChannelWebhook, Target: srv.URL}
+	if err := Send(context.Background(), &config.Config{}, ch, Notification{Event: EventResolved, Alert: testAlert()}); err != nil {
+		t.Fatal(err)
+	}
+	if sig := requests()[0].header.Get("X-Wechat2RSS-Signature"); sig != "" {
+		t.Errorf("unexpected signature %q", sig)
+	}
+}
+
+func TestWebhookStatus(t *testing.T) {
+	srv, _ := standIn(t, http.StatusServiceUnavailable, "")
+	ch := &models.AlertChannel{Kind: models.AlertChannelWebhook, Target: srv.URL}
+	err := Send(context.Background(), &config.Config{}, ch, Notification{Event: EventRaised, Alert: testAlert()})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("err = %v", err)
+	}
+}
+
+func TestChatPayloads(t *testing.T) {
+	cases := []struct {
+		kind  string
+		reply string
+		check func(t *testing.T, req request, payload map[string]any)
+	}{
+		{models.AlertChannelWeCom, `{"errcode":0,"errmsg":"ok"}`, func(t *testing.T, req request, payload map[string]any) {
+			if payload["msgtype"] != "text" || !strings.Contains(payload["text"].(map[string]any)["content"].(string), "[严重]") {
+				t.Errorf("wecom payload %v", payload)
+			}
+		}},
+		{models.AlertChannelDingTalk, `{"errcode":0,"errmsg":"ok"}`, func(t *testing.T, req request, payload map[string]any) {
+			if payload["msgtype"] != "text" || payload["text"].(map[string]any)["content"] == "" {
+				t.Errorf("dingtalk payload %v", payload)
+			}
+			u, _ := http.NewRequest(http.MethodGet, req.url, nil)
+			ts, sign := u.URL.Query().Get("timestamp"), u.URL.Query().Get("sign")
+			mac := hmac.New(sha256.New, []byte("robot"))
+			mac.Write([]byte(ts + "\n" + "robot"))
+			if sign != base64.StdEncoding.EncodeToString(mac.Sum(nil)) || u.URL.Query().Get("access_token") != "abc" {
+				t.Errorf("dingtalk url %s", req.url)
+			}
+		}},
+		{models.AlertChannelFeishu, `{"code":0,"msg":"success"}`, func(t *testing.T, req request, payload map[string]any) {
+			if payload["msg_type"] != "text" || payload["content"].(map[string]any)["text"] == "" {
+				t.Errorf("feishu payload %v", payload)
+			}
+			ts := payload["timestamp"].(string)
+			mac := hmac.New(sha256.New, []byte(ts+"\n"+"robot"))
+			if payload["sign"] != base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+				t.Errorf("feishu sign %v", payload["sign"])
+			}
+		}},
+		{models.AlertChannelSlack, "ok", func(t *testing.T, req request, payload map[string]any) {
+			if len(payload) != 1 || !strings.HasPrefix(payload["text"].(string), "Wechat2RSS [严重]") {
+				t.Errorf("slack payload %v", payload)
+			}
+		}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.kind, func(t *testing.T) {
+			srv, requests := standIn(t, http.StatusOK, tc.reply)
+			ch := &models.AlertChannel{Kind: tc.kind, Target: srv.URL + "/robot?access_token=abc", Secret: "robot"}
+			if err := ValidateChannel(&config.Config{}, ch); err != nil {
+				t.Fatal(err)
+			}
+			if err := Send(context.Background(), &config.Config{}, ch, Notification{Event: EventRaised, Alert: testAlert()}); err != nil {
+				t.Fatal(err)
+			}
+			req := requests()[0]
+			var payload map[string]any
+			if err := json.Unmarshal(req.body, &payload); err != nil {
+				t.Fatal(err)
+			}
+			tc.check(t, req, payload)
+		})
+	}
+}
+
+func TestChatErrorReply(t *testing.T) {
+	for kind, reply := range map[string]string{
+		models.AlertChannelWeCom:  `{"errcode":93000,"errmsg":"invalid webhook url"}`,
+		models.AlertChannelFeishu: `{"code":19021,"msg":"sign match fail"}`,
+	} {
+		srv, _ := standIn(t, http.StatusOK, reply)
+		ch := &models.AlertChannel{Kind: kind, Target: srv.URL}
+		if err := Send(context.Background(), &config.Config{}, ch, Notification{Event: EventRaised, Alert: testAlert()}); err == nil {
+			t.Errorf("%s: error reply accepted", kind)
+		}
+	}
+}
+
+// smtpStandIn accepts one SMTP session without TLS or authentication and
+// returns the envelope and message it received.
+func smtpStandIn(t *testing.T) (host string, port int, result func() (rcpts []string, data string)) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	done := make(chan struct{})
+	var (
+		rcpts []string
+		data  strings.Builder
+	)
+	go func() {
+		defer close(done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 stand-in ESMTP")
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if inData {
+				if line == ".\r\n" {
+					inData = false
+					reply("250 queued")
+				} else {
+					data.WriteString(line)
+				}
+				continue
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 stand-in")
+			case strings.HasPrefix(cmd, "RCPT TO:"):
+				rcpts = append(rcpts, strings.TrimSpace(line[len("RCPT TO:"):]))
+				reply("250 ok")
+			case cmd == "DATA":
+				inData = true
+				reply("354 go ahead")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+	return "127.0.0.1", addr.Port, func() ([]string, string) {
+		<-done
+		return rcpts, data.String()
+	}
+}
+
+func TestEmailNotifier(t *testing.T) {
+	host, port, result := smtpStandIn(t)
+	cfg := &config.Config{SMTPHost: host, SMTPPort: port, SMTPFrom: "Wechat2RSS <alert@example.com>"}
+	ch := &models.AlertChannel{Kind: models.AlertChannelEmail, Target: "ops@example.com, Bob <bob@example.com>"}
+	if err := ValidateChannel(cfg, ch); err != nil {
+		t.Fatal(err)
+	}
+	if err := Send(context.Background(), cfg, ch, Notification{Event: EventRaised, Alert: testAlert()}); err != nil {
+		t.Fatal(err)
+	}
+
+	rcpts, data := result()
+	if strings.Join(rcpts, ",") != "<ops@example.com>,<bob@example.com>" {
+		t.Errorf("recipients %v", rcpts)
+	}
+	header, body, ok := strings.Cut(data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header separator in %q", data)
+	}
+	var subject string
+	for _, line := range strings.Split(header, "\r\n") {
+		if v, ok := strings.CutPrefix(line, "Subject: "); ok {
+			subject, _ = new(mime.WordDecoder).DecodeHeader(v)
+		}
+	}
+	if subject != "Wechat2RSS [严重] 公众号后台会话 #3 已失效" {
+		t.Errorf("subject %q", subject)
+	}
+	text, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(body, "\r\n", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(text), "告警 ID：7") {
+		t.Errorf("body %q", text)
+	}
+}
+
+func TestEmailRequiresSMTP(t *testing.T) {
+	ch := &models.AlertChannel{Kind: models.AlertChannelEmail, Target: "ops@example.com"}
+	if err := ValidateChannel(&config.Config{}, ch); err == nil {
+		t.Error("email channel accepted without SMTP_HOST")
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	warning := testAlert()
+	warning.Severity = models.AlertSeverityWarning
+	cases := []struct {
+		ch   models.AlertChannel
+		a    *models.Alert
+		want bool
+	}{
+		{models.AlertChannel{}, warning, true},
+		{models.AlertChannel{MinSeverity: models.AlertSeverityCritical}, warning, false},
+		{models.AlertChannel{MinSeverity: models.AlertSeverityCritical}, testAlert(), true},
+		{models.AlertChannel{Types: "task_failures,session_expired"}, testAlert(), true},
+		{models.AlertChannel{Types: "task_failures"}, testAlert(), false},
+	}
+	for i, tc := range cases {
+		if got := Routes(&tc.ch, tc.a); got != tc.want {
+			t.Errorf("case %d: Routes = %t, want %t", i, got, tc.want)
+		}
+	}
+}
+
+func TestDispatcherRateLimit(t *testing.T) {
+	d := newDispatcher(&config.Config{AlertNotifyRate: 2}, nil)
+	if !d.allow(1) || !d.allow(1) {
+		t.Fatal("budget refused too early")
+	}
+	if d.allow(1) {
+		t.Error("third delivery within the hour allowed")
+	}
+	if !d.allow(2) {
+		t.Error("budget shared between channels")
+	}
+
+	// attempts older than an hour no longer count
+	d.sent[1] = []time.Time{time.Now().Add(-2 * time.Hour), time.Now().Add(-90 * time.Minute)}
+	if !d.allow(1) {
+		t.Error("expired attempts still counted")
+	}
+
+	unlimited := newDispatcher(&config.Config{}, nil)
+	for range 100 {
+		if !unlimited.allow(1) {
+			t.Fatal("ALERT_NOTIFY_RATE=0 limited deliveries")
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/wechat"
+)
+
+// rules returns the rules enabled by configuration; a threshold of zero
+// turns a rule off.
+func (e *Engine) rules() []rule {
+	rules := []rule{{models.AlertSessionExpired, e.checkSessions}}
+	if e.cfg.AlertTaskFailures > 0 {
+		rules = append(rules, rule{models.AlertTaskFailures, e.checkTaskFailures})
+	}
+	if e.cfg.AlertStaleDays > 0 {
+		rules = append(rules, rule{models.AlertStaleAccount, e.checkStaleAccounts})
+	}
+	if e.cfg.AlertFreqWindow > 0 {
+		rules = append(rules, rule{models.AlertFreqControl, e.checkFreqControl})
+	}
+	if e.cfg.AlertStallMinutes > 0 {
+		rules = append(rules, rule{models.AlertCrawlerStall, e.checkCrawlerStall})
+	}
+	return rules
+}
+
+// checkSessions finds mp logins that expired, or outlived their expiry
+// time, while active accounts still crawl through them.
+func (e *Engine) checkSessions(ctx context.Context) ([]finding, error) {
+	var rows []struct {
+		ID        uint
+		Status    string
+		ExpiresAt *time.Time
+		Accounts  int
+	}
+	if err := e.db.WithContext(ctx).Model(&models.WechatSession{}).
+		Select("wechat_sessions.id, wechat_sessions.status, wechat_sessions.expires_at, COUNT(accounts.id) AS accounts").
+		Joins("JOIN accounts ON accounts.session_id = wechat_sessions.id AND accounts.status = ?", "active").
+		Where("wechat_sessions.status = ? OR (wechat_sessions.status = ? AND wechat_sessions.expires_at < ?)",
+			models.SessionStatusExpired, models.SessionStatusActive, time.Now()).
+		Group("wechat_sessions.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	findings := make([]finding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, finding{
+			key:       "session:" + strconv.Itoa(int(row.ID)),
+			severity:  models.AlertSeverityCritical,
+			message:   fmt.Sprintf("公众号后台会话 #%d 已失效，%d 个公众号无法抓取，请重新扫码登录", row.ID, row.Accounts),
+			sessionID: &row.ID,
+			payload: map[string]any{
+				"session_id": row.ID,
+				"status":     row.Status,
+				"expires_at": row.ExpiresAt,
+				"accounts":   row.Accounts,
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkTaskFailures finds accounts whose last ALERT_TASK_FAILURES finished
+// tasks all failed after exhausting their retries.
+func (e *Engine) checkTaskFailures(ctx context.Context) ([]finding, error) {
+	limit := e.cfg.AlertTaskFailures
+	var rows []struct {
+		AccountID uint
+		Name      string
+		LastError string
+		LastAt    *time.Time
+	}
+	if err := e.db.WithContext(ctx).Raw(`
+		SELECT recent.account_id, accounts.name,
+			(array_agg(recent.error_msg ORDER BY recent.rn))[1] AS last_error,
+			MAX(recent.finished_at) AS last_at
+		FROM (
+			SELECT account_id, status, error_msg, finished_at,
+				ROW_NUMBER() OVER (PARTITION BY account_id ORDER BY finished_at DESC, id DESC) AS rn
+			FROM tasks
+			WHERE status IN ?
+		) recent
+		JOIN accounts ON accounts.id = recent.account_id
+		WHERE recent.rn <= ?
+		GROUP BY recent.account_id, accounts.name
+		HAVING COUNT(*) = ? AND bool_and(recent.status = ?)`,
+		[]string{models.TaskStatusSuccess, models.TaskStatusFailed}, limit, limit, models.TaskStatusFailed).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	findings := make([]finding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, finding{
+			key:       "account:" + strconv.Itoa(int(row.AccountID)),
+			severity:  models.AlertSeverityWarning,
+			message:   fmt.Sprintf("公众号「%s」最近 %d 次抓取任务均失败：%s", row.Name, limit, row.LastError),
+			accountID: &row.AccountID,
+			payload: map[string]any{
+				"account_id": row.AccountID,
+				"failures":   limit,
+				"last_error": row.LastError,
+				"last_at":    row.LastAt,
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkStaleAccounts finds active accounts without a new article for
+// ALERT_STALE_DAYS days, which usually means crawling silently stopped.
+// Accounts younger than that are left alone.
+func (e *Engine) checkStaleAccounts(ctx context.Context) ([]finding, error) {
+	cutoff := time.Now().AddDate(0, 0, -e.cfg.AlertStaleDays)
+	var rows []struct {
+		ID            uint
+		Name          string
+		LastPublished *time.Time
+	}
+	if err := e.db.WithContext(ctx).Model(&models.Account{}).
+		Select("accounts.id, accounts.name, MAX(articles.published_at) AS last_published").
+		Joins("LEFT JOIN articles ON articles.account_id = accounts.id").
+		Where("accounts.status = ? AND accounts.created_at < ?", "active", cutoff).
+		Group("accounts.id").
+		Having("MAX(articles.published_at) IS NULL OR MAX(articles.published_at) < ?", cutoff).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	findings := make([]finding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, finding{
+			key:       "account:" + strconv.Itoa(int(row.ID)),
+			severity:  models.AlertSeverityWarning,
+			message:   fmt.Sprintf("公众号「%s」已超过 %d 天没有新文章", row.Name, e.cfg.AlertStaleDays),
+			accountID: &row.ID,
+			payload: map[string]any{
+				"account_id":     row.ID,
+				"last_published": row.LastPublished,
+				"days":           e.cfg.AlertStaleDays,
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkFreqControl finds mp logins that hit the backend's rate limit
+// (ret 200013) within the last ALERT_FREQ_WINDOW minutes. Task logs are
+// used because a retried task clears its error.
+func (e *Engine) checkFreqControl(ctx context.Context) ([]finding, error) {
+	since := time.Now().Add(-time.Duration(e.cfg.AlertFreqWindow) * time.Minute)
+	var rows []struct {
+		SessionID uint
+		Hits      int
+		LastHit   time.Time
+	}
+	if err := e.db.WithContext(ctx).Model(&models.TaskLog{}).
+		Select("accounts.session_id, COUNT(*) AS hits, MAX(task_logs.created_at) AS last_hit").
+		Joins("JOIN tasks ON tasks.id = task_logs.task_id").
+		Joins("JOIN accounts ON accounts.id = tasks.account_id").
+		Where("task_logs.level = ? AND task_logs.created_at > ? AND task_logs.message LIKE ? AND accounts.session_id IS NOT NULL",
+			"error", since, fmt.Sprintf("%%ret %d %%", wechat.RetFreqControl)).
+		Group("accounts.session_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	findings := make([]finding, 0, len(rows))
+	for _, row := range rows {
+		findings = append(findings, finding{
+			key:       "session:" + strconv.Itoa(int(row.SessionID)),
+			severity:  models.AlertSeverityWarning,
+			message:   fmt.Sprintf("公众号后台会话 #%d 触发频率限制（%d 次），请降低抓取频率", row.SessionID, row.Hits),
+			sessionID: &row.SessionID,
+			payload: map[string]any{
+				"session_id": row.SessionID,
+				"hits":       row.Hits,
+				"last_hit":   row.LastHit,
+			},
+		})
+	}
+	return findings, nil
+}
+
+// checkCrawlerStall raises a single alert when tasks sit running or
+// pending for longer than ALERT_STALL_MINUTES. A task never runs longer
+// than a few minutes, so either points at a stuck or stopped crawler.
+func (e *Engine) checkCrawlerStall(ctx context.Context) ([]finding, error) {
+	cutoff := time.Now().Add(-time.Duration(e.cfg.AlertStallMinutes) * time.Minute)
+	var stuck, waiting int64
+	if err := e.db.WithContext(ctx).Model(&models.Task{}).
+		Where("status = ? AND started_at < ?", models.TaskStatusRunning, cutoff).
+		Count(&stuck).Error; err != nil {
+		return nil, err
+	}
+	if err := e.db.WithContext(ctx).Model(&models.Task{}).
+		Where("status = ? AND updated_at < ?", models.TaskStatusPending, cutoff).
+		Count(&waiting).Error; err != nil {
+		return nil, err
+	}
+	if stuck == 0 && waiting == 0 {
+		return nil, nil
+	}
+	return []finding{{
+		key:      "crawler",
+		severity: models.AlertSeverityCritical,
+		message: fmt.Sprintf("抓取队列停滞：%d 个任务运行超过 %d 分钟，%d 个任务等待超过 %d 分钟",
+			stuck, e.cfg.AlertStallMinutes, waiting, e.cfg.AlertStallMinutes),
+		payload: map[string]any{
+			"running": stuck,
+			"pending": waiting,
+			"minutes": e.cfg.AlertStallMinutes,
+		},
+	}}, nil
+}
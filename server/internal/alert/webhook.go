@@ -0,0 +1,150 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"wechat2rss/internal/models"
+)
+
+// webhookNotifier posts the alert as JSON. With a secret the request
+// carries X-Wechat2RSS-Timestamp and X-Wechat2RSS-Signature, the hex
+// HMAC-SHA256 of "<timestamp>.<body>", so receivers can reject forged or
+// replayed requests.
+type webhookNotifier struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (w *webhookNotifier) Notify(ctx context.Context, n Notification) error {
+	body, err := json.Marshal(map[string]any{
+		"event": n.Event,
+		"alert": toAlertPayload(n.Alert),
+		"url":   n.URL,
+	})
+	if err != nil {
+		return err
+	}
+	headers := http.Header{}
+	headers.Set("X-Wechat2RSS-Event", n.Event)
+	if w.secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		mac := hmac.New(sha256.New, []byte(w.secret))
+		mac.Write([]byte(ts + "."))
+		mac.Write(body)
+		headers.Set("X-Wechat2RSS-Timestamp", ts)
+		headers.Set("X-Wechat2RSS-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+	_, err = postJSON(ctx, w.client, w.url, headers, body)
+	return err
+}
+
+// chatNotifier posts a text message to a chat robot webhook in the
+// payload format of its kind. DingTalk and Feishu robots with signature
+// verification enabled need their secret.
+type chatNotifier struct {
+	kind   string
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (c *chatNotifier) Notify(ctx context.Context, n Notification) error {
+	target := c.url
+	var payload map[string]any
+	switch c.kind {
+	case models.AlertChannelWeCom:
+		payload = map[string]any{"msgtype": "text", "text": map[string]string{"content": n.text()}}
+	case models.AlertChannelDingTalk:
+		payload = map[string]any{"msgtype": "text", "text": map[string]string{"content": n.text()}}
+		if c.secret != "" {
+			ts := strconv.FormatInt(time.Now().UnixMilli(), 10)
+			mac := hmac.New(sha256.New, []byte(c.secret))
+			mac.Write([]byte(ts + "\n" + c.secret))
+			u, err := url.Parse(target)
+			if err != nil {
+				return err
+			}
+			q := u.Query()
+			q.Set("timestamp", ts)
+			q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+			u.RawQuery = q.Encode()
+			target = u.String()
+		}
+	case models.AlertChannelFeishu:
+		payload = map[string]any{"msg_type": "text", "content": map[string]string{"text": n.text()}}
+		if c.secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(ts+"\n"+c.secret))
+			payload["timestamp"] = ts
+			payload["sign"] = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+		}
+	case models.AlertChannelSlack:
+		payload = map[string]any{"text": n.text()}
+	default:
+		return fmt.Errorf("unknown chat kind %q", c.kind)
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	resp, err := postJSON(ctx, c.client, target, nil, body)
+	if err != nil {
+		return err
+	}
+	// the robots answer 200 and report failures in the body
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if json.Unmarshal(resp, &result) == nil {
+		if result.ErrCode != 0 {
+			return fmt.Errorf("%s errcode %d: %s", c.kind, result.ErrCode, result.ErrMsg)
+		}
+		if result.Code != 0 {
+			return fmt.Errorf("%s code %d: %s", c.kind, result.Code, result.Msg)
+		}
+	}
+	return nil
+}
+
+// postJSON posts body and returns the start of a 2xx response.
+func postJSON(ctx context.Context, client *http.Client, target string, headers http.Header, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for key := range headers {
+		req.Header.Set(key, headers.Get(key))
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Wechat2RSS")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return data, nil
+}
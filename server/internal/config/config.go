@@ -4,21 +4,62 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
+// defaultSessionSecret is only acceptable outside production.
+const defaultSessionSecret = "wechat2rss-secret"
+
 // Config holds runtime configuration loaded from environment variables.
 type Config struct {
+	Env               string
 	Port              int
 	DatabaseURL       string
 	SessionSecret     string
+	SessionIdle       int
+	SessionMaxAge     int
+	CookieSecure      bool
+	CookieSameSite    string
+	CookieDomain      string
 	AdminUser         string
 	AdminPassword     string
 	ChromiumPath      string
 	CrawlerConcurrent int
 	TaskPollInterval  int
 	StaticDir         string
+	RecheckInterval   int
+	RecheckSample     int
+	RecheckWindowDays int
+	SearchConfig      string
+	PublicBaseURL     string
+	FeedCacheTTL      int
+	FeedMaxItems      int
+	WebSubHub         string
+	WebSubLease       int
+	WebSubPrivate     bool
+	WebSubMaxPerHost  int
+	WebSubMaxPending  int
+	TrustedProxies    []string
+	CORSOrigins       []string
+	AuditRetention    int
+	AlertInterval     int
+	AlertTaskFailures int
+	AlertStaleDays    int
+	AlertFreqWindow   int
+	AlertStallMinutes int
+	AlertNotifyRate   int
+	SMTPHost          string
+	SMTPPort          int
+	SMTPUsername      string
+	SMTPPassword      string
+	SMTPFrom          string
+	SMTPImplicitTLS   bool
+	LoginMaxFailures  int
+	LoginMaxIPFails   int
+	LoginWindow       int
+	LoginLockout      int
 }
 
 // Load reads environment variables (populating defaults) and returns Config.
@@ -26,24 +67,85 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
+		Env:               getStr("APP_ENV", "development"),
 		Port:              getInt("APP_PORT", 8080),
 		DatabaseURL:       os.Getenv("DATABASE_URL"),
-		SessionSecret:     getStr("SESSION_SECRET", "wechat2rss-secret"),
+		SessionSecret:     getStr("SESSION_SECRET", defaultSessionSecret),
+		SessionIdle:       getInt("SESSION_IDLE_TIMEOUT", 120),
+		SessionMaxAge:     getInt("SESSION_MAX_AGE", 168),
+		CookieSameSite:    strings.ToLower(getStr("SESSION_COOKIE_SAMESITE", "lax")),
+		CookieDomain:      os.Getenv("SESSION_COOKIE_DOMAIN"),
 		AdminUser:         getStr("ADMIN_USER", "admin"),
 		AdminPassword:     getStr("ADMIN_PASSWORD", "changeme"),
 		ChromiumPath:      os.Getenv("CHROMIUM_PATH"),
 		CrawlerConcurrent: getInt("CRAWLER_CONCURRENCY", 1),
 		TaskPollInterval:  getInt("TASK_POLL_INTERVAL", 5),
 		StaticDir:         os.Getenv("WEB_STATIC_DIR"),
+		RecheckInterval:   getInt("RECHECK_INTERVAL", 60),
+		RecheckSample:     getInt("RECHECK_SAMPLE", 20),
+		RecheckWindowDays: getInt("RECHECK_WINDOW_DAYS", 30),
+		SearchConfig:      os.Getenv("SEARCH_TS_CONFIG"),
+		PublicBaseURL:     strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/"),
+		FeedCacheTTL:      getInt("FEED_CACHE_TTL", 600),
+		FeedMaxItems:      getInt("FEED_MAX_ITEMS", 200),
+		WebSubHub:         os.Getenv("WEBSUB_HUB"),
+		WebSubLease:       getInt("WEBSUB_LEASE_SECONDS", 864000),
+		WebSubPrivate:     getBool("WEBSUB_ALLOW_PRIVATE_CALLBACKS", false),
+		WebSubMaxPerHost:  getInt("WEBSUB_MAX_PER_HOST", 20),
+		WebSubMaxPending:  getInt("WEBSUB_MAX_PENDING", 32),
+		TrustedProxies:    getList("TRUSTED_PROXIES"),
+		CORSOrigins:       getList("CORS_ALLOWED_ORIGINS"),
+		AuditRetention:    getInt("AUDIT_RETENTION_DAYS", 365),
+		AlertInterval:     getInt("ALERT_CHECK_INTERVAL", 5),
+		AlertTaskFailures: getInt("ALERT_TASK_FAILURES", 3),
+		AlertStaleDays:    getInt("ALERT_STALE_DAYS", 7),
+		AlertFreqWindow:   getInt("ALERT_FREQ_WINDOW", 60),
+		AlertStallMinutes: getInt("ALERT_STALL_MINUTES", 30),
+		AlertNotifyRate:   getInt("ALERT_NOTIFY_RATE", 20),
+		SMTPHost:          os.Getenv("SMTP_HOST"),
+		SMTPPort:          getInt("SMTP_PORT", 587),
+		SMTPUsername:      os.Getenv("SMTP_USERNAME"),
+		SMTPPassword:      os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:          os.Getenv("SMTP_FROM"),
+		SMTPImplicitTLS:   getBool("SMTP_TLS", false),
+		LoginMaxFailures:  getInt("LOGIN_MAX_FAILURES", 5),
+		LoginMaxIPFails:   getInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginWindow:       getInt("LOGIN_FAILURE_WINDOW", 15),
+		LoginLockout:      getInt("LOGIN_LOCKOUT_MINUTES", 15),
+	}
+
+	cfg.CookieSecure = getBool("SESSION_COOKIE_SECURE", cfg.Production())
+	if cfg.SMTPFrom == "" {
+		cfg.SMTPFrom = cfg.SMTPUsername
 	}
 
 	if cfg.DatabaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
+	if cfg.Production() && (cfg.SessionSecret == defaultSessionSecret || len(cfg.SessionSecret) < 32) {
+		return nil, fmt.Errorf("SESSION_SECRET must be set to a random value of at least 32 characters in production")
+	}
+	switch cfg.CookieSameSite {
+	case "lax", "strict", "none":
+	default:
+		return nil, fmt.Errorf("SESSION_COOKIE_SAMESITE must be lax, strict or none")
+	}
+	if cfg.CookieSameSite == "none" && !cfg.CookieSecure {
+		return nil, fmt.Errorf("SESSION_COOKIE_SAMESITE=none requires SESSION_COOKIE_SECURE")
+	}
+
+	if cfg.SMTPHost != "" && cfg.SMTPFrom == "" {
+		return nil, fmt.Errorf("SMTP_FROM is required when SMTP_HOST is set")
+	}
 
 	return cfg, nil
 }
 
+// Production reports APP_ENV=production.
+func (c *Config) Production() bool {
+	return c.Env == "production"
+}
+
 func getStr(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -59,3 +161,22 @@ func getInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+func getBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if parsed, err := strconv.ParseBool(v); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
+
+func getList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,237 @@
+package content
+
+import (
+	"html"
+	"strings"
+	"unicode"
+)
+
+// maxCellDiff bounds the LCS work (n*m) for a single paragraph pair;
+// larger pairs are shown as a whole deletion plus insertion. Memory stays
+// linear in the input either way.
+const maxCellDiff = 4_000_000
+
+type opKind int
+
+const (
+	opEqual opKind = iota
+	opDelete
+	opInsert
+)
+
+type diffOp struct {
+	kind  opKind
+	value string
+}
+
+// DiffHTML compares the text of two HTML bodies and renders the result as
+// HTML, wrapping removed text in <del> and added text in <ins>.
+func DiffHTML(oldHTML, newHTML string) string {
+	oldParas := Paragraphs(oldHTML)
+	newParas := Paragraphs(newHTML)
+
+	var sb strings.Builder
+	sb.WriteString(`<div class="diff">`)
+	ops := diffSlices(oldParas, newParas)
+	for i := 0; i < len(ops); i++ {
+		op := ops[i]
+		switch op.kind {
+		case opEqual:
+			writeParagraph(&sb, "", html.EscapeString(op.value))
+		case opDelete:
+			// a deletion directly followed by an insertion is an edited
+			// paragraph; diff it inline
+			if i+1 < len(ops) && ops[i+1].kind == opInsert {
+				writeParagraph(&sb, "", diffInline(op.value, ops[i+1].value))
+				i++
+				continue
+			}
+			writeParagraph(&sb, "del", html.EscapeString(op.value))
+		case opInsert:
+			writeParagraph(&sb, "ins", html.EscapeString(op.value))
+		}
+	}
+	sb.WriteString(`</div>`)
+	return sb.String()
+}
+
+func writeParagraph(sb *strings.Builder, wrap, inner string) {
+	sb.WriteString("<p>")
+	if wrap != "" {
+		sb.WriteString("<" + wrap + ">" + inner + "</" + wrap + ">")
+	} else {
+		sb.WriteString(inner)
+	}
+	sb.WriteString("</p>")
+}
+
+func diffInline(oldText, newText string) string {
+	oldTokens := tokenize(oldText)
+	newTokens := tokenize(newText)
+	if len(oldTokens)*len(newTokens) > maxCellDiff {
+		return "<del>" + html.EscapeString(oldText) + "</del><ins>" + html.EscapeString(newText) + "</ins>"
+	}
+
+	var sb strings.Builder
+	var (
+		pending     strings.Builder
+		pendingKind = opEqual
+	)
+	flush := func() {
+		if pending.Len() == 0 {
+			return
+		}
+		text := html.EscapeString(pending.String())
+		switch pendingKind {
+		case opDelete:
+			sb.WriteString("<del>" + text + "</del>")
+		case opInsert:
+			sb.WriteString("<ins>" + text + "</ins>")
+		default:
+			sb.WriteString(text)
+		}
+		pending.Reset()
+	}
+	for _, op := range diffSlices(oldTokens, newTokens) {
+		if op.kind != pendingKind {
+			flush()
+			pendingKind = op.kind
+		}
+		pending.WriteString(op.value)
+	}
+	flush()
+	return sb.String()
+}
+
+// tokenize splits text into words for Latin scripts and single runes for
+// CJK and punctuation, keeping whitespace as separate tokens.
+func tokenize(text string) []string {
+	var (
+		tokens []string
+		word   strings.Builder
+	)
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+	for _, r := range text {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			word.WriteRune(r)
+			continue
+		}
+		flushWord()
+		tokens = append(tokens, string(r))
+	}
+	flushWord()
+	return tokens
+}
+
+// diffSlices computes a minimal edit script between a and b using the
+// longest common subsequence.
+func diffSlices(a, b []string) []diffOp {
+	// trim common prefix and suffix to keep the work small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, v := range a[:prefix] {
+		ops = append(ops, diffOp{opEqual, v})
+	}
+
+	midA := a[prefix : len(a)-suffix]
+	midB := b[prefix : len(b)-suffix]
+	if len(midA)*len(midB) > maxCellDiff {
+		ops = appendOps(ops, opDelete, midA)
+		ops = appendOps(ops, opInsert, midB)
+	} else {
+		ops = hirschberg(ops, midA, midB)
+	}
+
+	for _, v := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{opEqual, v})
+	}
+	return ops
+}
+
+func appendOps(ops []diffOp, kind opKind, values []string) []diffOp {
+	for _, v := range values {
+		ops = append(ops, diffOp{kind, v})
+	}
+	return ops
+}
+
+// hirschberg appends an LCS edit script for a and b to ops. It splits a in
+// half, finds where the halves meet in b from LCS scores computed forward
+// and backward, and recurses, so only O(len(b)) memory is live at once.
+func hirschberg(ops []diffOp, a, b []string) []diffOp {
+	switch {
+	case len(a) == 0:
+		return appendOps(ops, opInsert, b)
+	case len(b) == 0:
+		return appendOps(ops, opDelete, a)
+	case len(a) == 1:
+		for j, v := range b {
+			if v == a[0] {
+				ops = appendOps(ops, opInsert, b[:j])
+				ops = append(ops, diffOp{opEqual, v})
+				return appendOps(ops, opInsert, b[j+1:])
+			}
+		}
+		ops = append(ops, diffOp{opDelete, a[0]})
+		return appendOps(ops, opInsert, b)
+	}
+
+	mid := len(a) / 2
+	head := lcsForward(a[:mid], b)
+	tail := lcsBackward(a[mid:], b)
+	split, best := 0, -1
+	for j := range head {
+		if score := head[j] + tail[j]; score > best {
+			split, best = j, score
+		}
+	}
+	ops = hirschberg(ops, a[:mid], b[:split])
+	return hirschberg(ops, a[mid:], b[split:])
+}
+
+// lcsForward returns, for each j, the LCS length of a and b[:j].
+func lcsForward(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := range a {
+		for j := range b {
+			if a[i] == b[j] {
+				cur[j+1] = prev[j] + 1
+			} else {
+				cur[j+1] = max(prev[j+1], cur[j])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsBackward returns, for each j, the LCS length of a and b[j:].
+func lcsBackward(a, b []string) []int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				cur[j] = prev[j+1] + 1
+			} else {
+				cur[j] = max(prev[j], cur[j+1])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
@@ -0,0 +1,67 @@
+package content
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// lcsLen is the quadratic reference the edit scripts are checked against.
+func lcsLen(a, b []string) int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+	return table[0][0]
+}
+
+func TestDiffSlicesMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	alphabet := []string{"a", "b", "c", "d"}
+	random := func() []string {
+		s := make([]string, rng.Intn(40))
+		for i := range s {
+			s[i] = alphabet[rng.Intn(len(alphabet))]
+		}
+		return s
+	}
+	for range 500 {
+		a, b := random(), random()
+		var gotA, gotB []string
+		equal := 0
+		for _, op := range diffSlices(a, b) {
+			switch op.kind {
+			case opEqual:
+				gotA, gotB = append(gotA, op.value), append(gotB, op.value)
+				equal++
+			case opDelete:
+				gotA = append(gotA, op.value)
+			case opInsert:
+				gotB = append(gotB, op.value)
+			}
+		}
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("script for %v -> %v does not reproduce the inputs", a, b)
+		}
+		if want := lcsLen(a, b); equal != want {
+			t.Fatalf("script for %v -> %v keeps %d tokens, LCS is %d", a, b, equal, want)
+		}
+	}
+}
+
+func TestDiffHTML(t *testing.T) {
+	got := DiffHTML("<p>第一段</p><p>hello old world</p><p>结尾</p>", "<p>第一段</p><p>hello new world</p><p>结尾</p><p>新增 & 更多</p>")
+	want := `<div class="diff"><p>第一段</p><p>hello <del>old</del><ins>new</ins> world</p><p>结尾</p><p><ins>新增 &amp; 更多</ins></p></div>`
+	if got != want {
+		t.Errorf("DiffHTML =\n%s\nwant\n%s", got, want)
+	}
+}
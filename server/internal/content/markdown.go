@@ -0,0 +1,207 @@
+package content
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Markdown converts article HTML into a Markdown rendition. WeChat bodies
+// nest most text in styled section/span elements, so only semantic tags
+// are mapped and everything else is flattened.
+func Markdown(raw string) string {
+	nodes, err := html.ParseFragment(strings.NewReader(raw), &html.Node{
+		Type:     html.ElementNode,
+		Data:     "div",
+		DataAtom: atom.Div,
+	})
+	if err != nil {
+		return ""
+	}
+	var sb strings.Builder
+	for _, n := range nodes {
+		sb.WriteString(markdownNode(n))
+	}
+	return tidyMarkdown(sb.String())
+}
+
+func markdownNode(n *html.Node) string {
+	switch n.Type {
+	case html.TextNode:
+		return collapseInline(n.Data)
+	case html.ElementNode:
+	default:
+		return markdownChildren(n)
+	}
+
+	switch n.Data {
+	case "script", "style", "noscript":
+		return ""
+	case "br":
+		return "\n"
+	case "hr":
+		return "\n\n---\n\n"
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level := int(n.Data[1] - '0')
+		text := strings.TrimSpace(strings.ReplaceAll(markdownChildren(n), "\n", " "))
+		if text == "" {
+			return ""
+		}
+		return "\n\n" + strings.Repeat("#", level) + " " + text + "\n\n"
+	case "strong", "b":
+		return wrapInline(markdownChildren(n), "**")
+	case "em", "i":
+		return wrapInline(markdownChildren(n), "*")
+	case "del", "s", "strike":
+		return wrapInline(markdownChildren(n), "~~")
+	case "code":
+		return wrapInline(textContent(n), "`")
+	case "pre":
+		return "\n\n```\n" + strings.Trim(textContent(n), "\n") + "\n```\n\n"
+	case "a":
+		text := markdownChildren(n)
+		href := attr(n, "href")
+		if href == "" || strings.HasPrefix(href, "javascript:") || strings.TrimSpace(text) == "" {
+			return text
+		}
+		return "[" + strings.TrimSpace(text) + "](" + href + ")"
+	case "img":
+		src := attr(n, "data-src")
+		if src == "" {
+			src = attr(n, "src")
+		}
+		if src == "" {
+			return ""
+		}
+		return "![" + attr(n, "alt") + "](" + src + ")"
+	case "ul", "ol":
+		var sb strings.Builder
+		index := 0
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode || child.Data != "li" {
+				continue
+			}
+			index++
+			marker := "- "
+			if n.Data == "ol" {
+				marker = strconv.Itoa(index) + ". "
+			}
+			item := tidyMarkdown(markdownChildren(child))
+			sb.WriteString(marker + strings.ReplaceAll(item, "\n", "\n   ") + "\n")
+		}
+		return "\n\n" + sb.String() + "\n"
+	case "blockquote":
+		inner := tidyMarkdown(markdownChildren(n))
+		if inner == "" {
+			return ""
+		}
+		return "\n\n> " + strings.ReplaceAll(inner, "\n", "\n> ") + "\n\n"
+	case "tr":
+		var cells []string
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && (child.Data == "td" || child.Data == "th") {
+				cells = append(cells, strings.TrimSpace(strings.ReplaceAll(markdownChildren(child), "\n", " ")))
+			}
+		}
+		return "\n| " + strings.Join(cells, " | ") + " |"
+	}
+
+	if blockTags[n.Data] {
+		return "\n\n" + markdownChildren(n) + "\n\n"
+	}
+	return markdownChildren(n)
+}
+
+func markdownChildren(n *html.Node) string {
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		sb.WriteString(markdownNode(child))
+	}
+	return sb.String()
+}
+
+func wrapInline(text, marker string) string {
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return text
+	}
+	return marker + trimmed + marker
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "br" {
+			sb.WriteString("\n")
+			continue
+		}
+		sb.WriteString(textContent(child))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// collapseInline folds whitespace runs inside a text node to one space.
+func collapseInline(s string) string {
+	var sb strings.Builder
+	space := false
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			if !space {
+				sb.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		space = false
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+// tidyMarkdown trims every line outside fenced code and collapses runs of
+// blank lines.
+func tidyMarkdown(s string) string {
+	var (
+		out     []string
+		inFence bool
+		blank   = true
+	)
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			out = append(out, strings.TrimSpace(line))
+			blank = false
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			if !blank {
+				out = append(out, "")
+			}
+			blank = true
+			continue
+		}
+		out = append(out, line)
+		blank = false
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
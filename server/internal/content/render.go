@@ -0,0 +1,51 @@
+package content
+
+import (
+	"unicode"
+)
+
+// Rendition holds the derived forms of an article body.
+type Rendition struct {
+	Text       string
+	Markdown   string
+	WordCount  int
+	ImageCount int
+}
+
+// Render derives the plain-text and Markdown renditions of article HTML.
+func Render(raw string) Rendition {
+	if raw == "" {
+		return Rendition{}
+	}
+	text := PlainText(raw)
+	return Rendition{
+		Text:       text,
+		Markdown:   Markdown(raw),
+		WordCount:  WordCount(text),
+		ImageCount: len(Images(raw)),
+	}
+}
+
+// WordCount counts CJK characters individually and other scripts by
+// whitespace or punctuation separated words, matching how Chinese article
+// lengths are usually reported.
+func WordCount(text string) int {
+	count := 0
+	inWord := false
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				count++
+			}
+			inWord = true
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
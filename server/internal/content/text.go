@@ -0,0 +1,111 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// blockTags start a new paragraph when flattening HTML to text.
+var blockTags = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "blockquote": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"li": true, "ul": true, "ol": true, "pre": true, "table": true, "tr": true, "td": true, "th": true,
+	"figure": true, "figcaption": true, "hr": true, "br": true,
+}
+
+// Paragraphs flattens article HTML into trimmed, non-empty text paragraphs.
+func Paragraphs(raw string) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	var (
+		paragraphs []string
+		current    strings.Builder
+	)
+	flush := func() {
+		text := collapseSpace(current.String())
+		if text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+		current.Reset()
+	}
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			current.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			switch n.Data {
+			case "script", "style", "noscript":
+				return
+			}
+			if blockTags[n.Data] {
+				flush()
+				defer flush()
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, n := range doc.Nodes {
+		walk(n)
+	}
+	flush()
+	return paragraphs
+}
+
+// PlainText returns the article text with one paragraph per line.
+func PlainText(raw string) string {
+	return strings.Join(Paragraphs(raw), "\n")
+}
+
+// Images lists image sources in document order, preferring the lazy-load
+// data-src WeChat uses over src.
+func Images(raw string) []string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	var images []string
+	doc.Find("img").Each(func(_ int, sel *goquery.Selection) {
+		src := sel.AttrOr("data-src", "")
+		if src == "" {
+			src = sel.AttrOr("src", "")
+		}
+		if src != "" {
+			images = append(images, src)
+		}
+	})
+	return images
+}
+
+// Hash returns a digest of the normalised body: its text paragraphs plus
+// image URLs with query strings removed, so markup churn and rotating CDN
+// parameters do not count as edits.
+func Hash(raw string) string {
+	h := sha256.New()
+	for _, p := range Paragraphs(raw) {
+		h.Write([]byte(p))
+		h.Write([]byte{'\n'})
+	}
+	for _, src := range Images(raw) {
+		if idx := strings.IndexByte(src, '?'); idx >= 0 {
+			src = src[:idx]
+		}
+		h.Write([]byte(src))
+		h.Write([]byte{'\n'})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func collapseSpace(s string) string {
+	s = strings.ReplaceAll(s, " ", " ")
+	return strings.Join(strings.Fields(s), " ")
+}
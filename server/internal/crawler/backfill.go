@@ -0,0 +1,54 @@
+package crawler
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
+)
+
+// BackfillRenditions derives the plain-text and Markdown renditions for
+// stored articles that lack them, or for every article when all is set,
+// and refreshes their search vectors. It returns the number of updated
+// articles.
+func BackfillRenditions(ctx context.Context, db *gorm.DB, engine *search.Engine, all bool) (int, error) {
+	var (
+		lastID  uint
+		updated int
+	)
+	for {
+		query := db.Where("id > ? AND content_html <> ''", lastID)
+		if !all {
+			query = query.Where("content_text IS NULL OR content_text = ''")
+		}
+		var articles []models.Article
+		if err := query.Order("id").Limit(100).Find(&articles).Error; err != nil {
+			return updated, err
+		}
+		if len(articles) == 0 {
+			return updated, nil
+		}
+		for i := range articles {
+			article := &articles[i]
+			applyRendition(article)
+			if err := db.Model(article).Updates(map[string]any{
+				"content_text":     article.ContentText,
+				"content_markdown": article.ContentMarkdown,
+				"word_count":       article.WordCount,
+				"image_count":      article.ImageCount,
+			}).Error; err != nil {
+				return updated, err
+			}
+			if err := engine.Index(article); err != nil {
+				return updated, err
+			}
+			updated++
+			lastID = article.ID
+		}
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+	}
+}
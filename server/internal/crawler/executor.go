@@ -5,13 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"gorm.io/gorm"
 
+	"wechat2rss/internal/config"
 	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
 	"wechat2rss/internal/wechat"
 )
 
@@ -22,13 +27,19 @@ type Executor interface {
 
 // ArticleExecutor fetches articles via mp api and stores them.
 type ArticleExecutor struct {
+	cfg    *config.Config
 	db     *gorm.DB
+	search *search.Engine
+	hooks  *Hooks
 	client *http.Client
 }
 
-func NewArticleExecutor(db *gorm.DB) *ArticleExecutor {
+func NewArticleExecutor(cfg *config.Config, db *gorm.DB, engine *search.Engine, hooks *Hooks) *ArticleExecutor {
 	return &ArticleExecutor{
-		db: db,
+		cfg:    cfg,
+		db:     db,
+		search: engine,
+		hooks:  hooks,
 		client: &http.Client{
 			Timeout: 20 * time.Second,
 		},
@@ -52,20 +63,33 @@ func (e *ArticleExecutor) Execute(ctx context.Context, task *models.Task) error
 		Token:  account.Session.Token,
 	}
 
+	// notify once per task so listeners are not flooded while paging
+	changed := false
+	defer func() {
+		if changed {
+			e.hooks.articlesChanged(account.ID)
+		}
+	}()
+
 	offset := 0
 	const batch = 5
 	for {
 		resp, err := wechat.FetchArticles(ctx, cred, account.BizID, offset, batch)
 		if err != nil {
+			if wechat.IsRet(err, wechat.RetSessionExpired) {
+				e.expireSession(account.Session)
+			}
 			return fmt.Errorf("fetch articles: %w", err)
 		}
 		if len(resp.AppMsgList) == 0 {
 			break
 		}
 		for _, item := range resp.AppMsgList {
-			if err := e.saveArticle(ctx, account.ID, item); err != nil {
+			saved, err := e.saveArticle(ctx, account.ID, item)
+			if err != nil {
 				return err
 			}
+			changed = changed || saved
 		}
 		offset += len(resp.AppMsgList)
 		if offset >= resp.TotalCount {
@@ -81,54 +105,90 @@ func (e *ArticleExecutor) Execute(ctx context.Context, task *models.Task) error
 	return nil
 }
 
-func (e *ArticleExecutor) saveArticle(ctx context.Context, accountID uint, item wechat.ArticleItem) error {
+// expireSession marks a session the mp backend no longer accepts, so
+// further tasks fail fast and the session expiry alert fires.
+func (e *ArticleExecutor) expireSession(session *models.WechatSession) {
+	if err := e.db.Model(session).Update("status", models.SessionStatusExpired).Error; err != nil {
+		log.Printf("expire session %d error: %v", session.ID, err)
+	}
+}
+
+// saveArticle stores a new article or refreshes a recent one, reporting
+// whether anything changed.
+func (e *ArticleExecutor) saveArticle(ctx context.Context, accountID uint, item wechat.ArticleItem) (bool, error) {
 	var existing models.Article
 	if err := e.db.First(&existing, "wechat_article_id = ?", item.Aid).Error; err == nil {
-		return nil
+		if !needsRefresh(&existing, e.cfg.RecheckWindowDays, e.cfg.RecheckInterval) {
+			return false, nil
+		}
+		page, err := fetchContent(ctx, e.client, existing.RawURL)
+		if err != nil {
+			return false, nil
+		}
+		meta := articleMeta{Title: item.Title, Author: item.Author, Summary: item.Digest, CoverURL: item.Cover}
+		changed, err := refreshArticle(e.db, &existing, meta, page)
+		if err != nil || !changed {
+			return false, err
+		}
+		return true, e.search.Index(&existing)
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return err
+		return false, err
 	}
 
-	content, err := e.fetchContent(ctx, item.Link)
+	page, err := fetchContent(ctx, e.client, item.Link)
 	if err != nil {
-		content = ""
+		page = pageContent{Status: models.ArticleStatusNormal}
 	}
-	published := time.Unix(item.CreateTime, 0)
 
 	article := models.Article{
 		AccountID:       accountID,
 		WechatArticleID: item.Aid,
+		ItemIndex:       itemIndex(item),
 		Title:           item.Title,
+		Author:          item.Author,
 		Summary:         item.Digest,
-		ContentHTML:     content,
+		CoverURL:        item.Cover,
 		RawURL:          item.Link,
-		PublishedAt:     published,
+		PublishedAt:     time.Unix(item.CreateTime, 0),
+	}
+	if err := createArticle(e.db, &article, page); err != nil {
+		return false, err
 	}
-	return e.db.Create(&article).Error
+	return true, e.search.Index(&article)
 }
 
-func (e *ArticleExecutor) fetchContent(ctx context.Context, link string) (string, error) {
+// itemIndex returns the position of item within its push, falling back to
+// the suffix of aids shaped like "2247483650_2".
+func itemIndex(item wechat.ArticleItem) int {
+	if item.ItemIdx > 0 {
+		return item.ItemIdx
+	}
+	if i := strings.LastIndexByte(item.Aid, '_'); i >= 0 {
+		if n, err := strconv.Atoi(item.Aid[i+1:]); err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+func fetchContent(ctx context.Context, client *http.Client, link string) (pageContent, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, link, nil)
 	if err != nil {
-		return "", err
+		return pageContent{}, err
 	}
 	req.Header.Set("User-Agent", "Mozilla/5.0 Wechat2RSS")
-	resp, err := e.client.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return pageContent{}, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("fetch content %d: %s", resp.StatusCode, string(body))
+		return pageContent{}, fmt.Errorf("fetch content %d: %s", resp.StatusCode, string(body))
 	}
 	doc, err := goquery.NewDocumentFromReader(resp.Body)
 	if err != nil {
-		return "", err
-	}
-	html, err := doc.Find("#js_content").Html()
-	if err != nil {
-		return "", err
+		return pageContent{}, err
 	}
-	return html, nil
+	return extractContent(doc)
 }
@@ -0,0 +1,57 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+
+	"wechat2rss/internal/models"
+)
+
+// removalMarkers maps notice texts shown on the public article page to the
+// content status they indicate.
+var removalMarkers = []struct {
+	text   string
+	status string
+}{
+	{"该内容已被发布者删除", models.ArticleStatusDeleted},
+	{"此内容已被发布者删除", models.ArticleStatusDeleted},
+	{"该内容已被作者删除", models.ArticleStatusDeleted},
+	{"此内容因违规无法查看", models.ArticleStatusTakenDown},
+	{"此内容被多人投诉", models.ArticleStatusTakenDown},
+	{"涉嫌违反相关法律法规和政策", models.ArticleStatusTakenDown},
+	{"该内容暂时无法查看", models.ArticleStatusTakenDown},
+	{"此内容发送失败无法查看", models.ArticleStatusTakenDown},
+}
+
+// pageContent is the result of parsing a public article page.
+type pageContent struct {
+	HTML   string
+	Status string
+}
+
+// extractContent pulls the article body from doc, or recognises the notice
+// WeChat shows in place of deleted and censored articles.
+func extractContent(doc *goquery.Document) (pageContent, error) {
+	body := doc.Find("#js_content")
+	if body.Length() > 0 {
+		html, err := body.Html()
+		if err != nil {
+			return pageContent{}, err
+		}
+		if strings.TrimSpace(html) != "" {
+			return pageContent{HTML: html, Status: models.ArticleStatusNormal}, nil
+		}
+	}
+
+	notice := doc.Find(".weui-msg__title, .weui-msg__desc, .global_error_msg").Text()
+	if notice == "" {
+		notice = doc.Find("body").Text()
+	}
+	for _, marker := range removalMarkers {
+		if strings.Contains(notice, marker.text) {
+			return pageContent{Status: marker.status}, nil
+		}
+	}
+	return pageContent{Status: models.ArticleStatusNormal}, nil
+}
@@ -0,0 +1,29 @@
+package crawler
+
+import "sync"
+
+// Hooks fans out notifications about stored articles to other components,
+// such as the feed cache.
+type Hooks struct {
+	mu      sync.RWMutex
+	changed []func(accountID uint)
+}
+
+// OnArticlesChanged registers fn to run after articles of an account were
+// added or updated.
+func (h *Hooks) OnArticlesChanged(fn func(accountID uint)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.changed = append(h.changed, fn)
+}
+
+func (h *Hooks) articlesChanged(accountID uint) {
+	if h == nil {
+		return
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, fn := range h.changed {
+		fn(accountID)
+	}
+}
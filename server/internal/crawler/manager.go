@@ -11,6 +11,7 @@ import (
 
 	"wechat2rss/internal/config"
 	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
 )
 
 // Manager polls pending tasks and dispatches them to the executor.
@@ -22,8 +23,8 @@ type Manager struct {
 	running  chan struct{}
 }
 
-func NewManager(cfg *config.Config, db *gorm.DB) *Manager {
-	return newManagerWithTicker(cfg, db, time.Duration(cfg.TaskPollInterval)*time.Second, NewArticleExecutor(db))
+func NewManager(cfg *config.Config, db *gorm.DB, engine *search.Engine, hooks *Hooks) *Manager {
+	return newManagerWithTicker(cfg, db, time.Duration(cfg.TaskPollInterval)*time.Second, NewArticleExecutor(cfg, db, engine, hooks))
 }
 
 func newManagerWithTicker(cfg *config.Config, db *gorm.DB, interval time.Duration, executor Executor) *Manager {
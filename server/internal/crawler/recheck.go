@@ -0,0 +1,99 @@
+package crawler
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/config"
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
+)
+
+// Rechecker periodically revisits a sample of recent articles to detect
+// ones that were deleted by their author or taken down by the platform.
+type Rechecker struct {
+	cfg    *config.Config
+	db     *gorm.DB
+	search *search.Engine
+	hooks  *Hooks
+	client *http.Client
+}
+
+func NewRechecker(cfg *config.Config, db *gorm.DB, engine *search.Engine, hooks *Hooks) *Rechecker {
+	return &Rechecker{
+		cfg:    cfg,
+		db:     db,
+		search: engine,
+		hooks:  hooks,
+		client: &http.Client{
+			Timeout: 20 * time.Second,
+		},
+	}
+}
+
+// Start runs the re-check loop until ctx is cancelled. A non-positive
+// RecheckInterval disables it.
+func (r *Rechecker) Start(ctx context.Context) {
+	if r.cfg.RecheckInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(r.cfg.RecheckInterval) * time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.checkOnce(ctx); err != nil {
+				log.Printf("article recheck error: %v", err)
+			}
+		}
+	}
+}
+
+func (r *Rechecker) checkOnce(ctx context.Context) error {
+	since := time.Now().AddDate(0, 0, -r.cfg.RecheckWindowDays)
+	var articles []models.Article
+	if err := r.db.Where("content_status = ? AND published_at >= ?", models.ArticleStatusNormal, since).
+		Order("checked_at asc nulls first").
+		Limit(r.cfg.RecheckSample).
+		Find(&articles).Error; err != nil {
+		return err
+	}
+
+	for i := range articles {
+		if err := r.recheck(ctx, &articles[i]); err != nil {
+			log.Printf("recheck article %d error: %v", articles[i].ID, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(2 * time.Second):
+		}
+	}
+	return nil
+}
+
+func (r *Rechecker) recheck(ctx context.Context, article *models.Article) error {
+	page, err := fetchContent(ctx, r.client, article.RawURL)
+	if err != nil {
+		return err
+	}
+	if page.Status != models.ArticleStatusNormal {
+		log.Printf("article %d marked %s", article.ID, page.Status)
+	}
+	// the page carries no listing metadata; keep what is stored
+	changed, err := refreshArticle(r.db, article, metaOf(article), page)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		return nil
+	}
+	r.hooks.articlesChanged(article.AccountID)
+	return r.search.Index(article)
+}
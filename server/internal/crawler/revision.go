@@ -0,0 +1,182 @@
+package crawler
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/content"
+	"wechat2rss/internal/models"
+)
+
+// createArticle stores a newly discovered article and, when a body was
+// fetched, its first revision.
+func createArticle(db *gorm.DB, article *models.Article, page pageContent) error {
+	now := time.Now()
+	article.ContentHTML = page.HTML
+	article.ContentStatus = page.Status
+	article.CheckedAt = &now
+	if page.Status != models.ArticleStatusNormal {
+		article.RemovedAt = &now
+	}
+	if page.HTML != "" {
+		article.BodyHash = content.Hash(page.HTML)
+	}
+	applyRendition(article)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(article).Error; err != nil {
+			return err
+		}
+		if page.HTML == "" {
+			return nil
+		}
+		return tx.Create(revisionOf(article, 1)).Error
+	})
+}
+
+// articleMeta is the listing metadata of an article, which can be edited
+// without touching the body.
+type articleMeta struct {
+	Title    string
+	Author   string
+	Summary  string
+	CoverURL string
+}
+
+// metaOf returns the metadata stored on article.
+func metaOf(article *models.Article) articleMeta {
+	return articleMeta{Title: article.Title, Author: article.Author, Summary: article.Summary, CoverURL: article.CoverURL}
+}
+
+// applyMeta copies meta onto article and returns the columns that changed.
+func applyMeta(article *models.Article, meta articleMeta) map[string]any {
+	updates := map[string]any{}
+	set := func(column string, stored *string, value string) {
+		if *stored != value {
+			*stored = value
+			updates[column] = value
+		}
+	}
+	set("title", &article.Title, meta.Title)
+	set("author", &article.Author, meta.Author)
+	set("summary", &article.Summary, meta.Summary)
+	set("cover_url", &article.CoverURL, meta.CoverURL)
+	return updates
+}
+
+// refreshArticle applies re-fetched metadata and page to an article as
+// stored. Removal notices only flip the status and keep the archived
+// body; a body whose normalised hash changed is stored as a new revision.
+// Metadata edits are saved whenever they differ. It reports whether
+// anything visible in feeds changed.
+func refreshArticle(db *gorm.DB, article *models.Article, meta articleMeta, page pageContent) (bool, error) {
+	now := time.Now()
+	hash := ""
+	if page.Status == models.ArticleStatusNormal && page.HTML != "" {
+		hash = content.Hash(page.HTML)
+	}
+
+	if hash == "" || hash == article.BodyHash {
+		updates := applyMeta(article, meta)
+		changed := len(updates) > 0
+		updates["checked_at"] = now
+		switch {
+		case page.Status != models.ArticleStatusNormal:
+			if article.ContentStatus != page.Status {
+				changed = true
+				updates["content_status"] = page.Status
+				updates["removed_at"] = now
+			}
+		case hash != "":
+			if article.ContentStatus != models.ArticleStatusNormal {
+				changed = true
+				updates["content_status"] = models.ArticleStatusNormal
+				updates["removed_at"] = nil
+			}
+		}
+		// an empty page without a removal notice is inconclusive; the
+		// status, including a removal detected earlier, is kept
+		return changed, db.Model(article).Updates(updates).Error
+	}
+
+	return true, db.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&models.ArticleRevision{}).
+			Where("article_id = ?", article.ID).
+			Select("COALESCE(MAX(revision), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+		// articles archived before revisions existed get their stored row
+		// recorded as the first revision, before anything is overwritten
+		if latest == 0 && article.ContentHTML != "" {
+			if article.BodyHash == "" {
+				article.BodyHash = content.Hash(article.ContentHTML)
+			}
+			if article.BodyHash != hash {
+				latest = 1
+				if err := tx.Create(revisionOf(article, latest)).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		applyMeta(article, meta)
+		article.ContentHTML = page.HTML
+		article.BodyHash = hash
+		applyRendition(article)
+		if err := tx.Model(article).Updates(map[string]any{
+			"title":            article.Title,
+			"author":           article.Author,
+			"summary":          article.Summary,
+			"cover_url":        article.CoverURL,
+			"content_html":     article.ContentHTML,
+			"content_text":     article.ContentText,
+			"content_markdown": article.ContentMarkdown,
+			"word_count":       article.WordCount,
+			"image_count":      article.ImageCount,
+			"body_hash":        hash,
+			"content_status":   models.ArticleStatusNormal,
+			"removed_at":       nil,
+			"checked_at":       now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Create(revisionOf(article, latest+1)).Error
+	})
+}
+
+// applyRendition derives the plain-text and Markdown fields from
+// ContentHTML.
+func applyRendition(article *models.Article) {
+	r := content.Render(article.ContentHTML)
+	article.ContentText = r.Text
+	article.ContentMarkdown = r.Markdown
+	article.WordCount = r.WordCount
+	article.ImageCount = r.ImageCount
+}
+
+func revisionOf(article *models.Article, revision int) *models.ArticleRevision {
+	return &models.ArticleRevision{
+		ArticleID:   article.ID,
+		Revision:    revision,
+		Title:       article.Title,
+		Author:      article.Author,
+		Summary:     article.Summary,
+		ContentHTML: article.ContentHTML,
+		BodyHash:    article.BodyHash,
+	}
+}
+
+// needsRefresh reports whether an already stored article is recent enough
+// and was last checked long enough ago to be fetched again.
+func needsRefresh(article *models.Article, windowDays, intervalMinutes int) bool {
+	if windowDays <= 0 || article.PublishedAt.Before(time.Now().AddDate(0, 0, -windowDays)) {
+		return false
+	}
+	if article.CheckedAt == nil {
+		return true
+	}
+	return time.Since(*article.CheckedAt) >= time.Duration(intervalMinutes)*time.Minute
+}
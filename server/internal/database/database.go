@@ -18,13 +18,88 @@ func Connect(dsn string) (*gorm.DB, error) {
 
 // AutoMigrate runs schema migrations for core models.
 func AutoMigrate(db *gorm.DB) error {
-	return db.AutoMigrate(
+	if err := db.AutoMigrate(&models.Setting{}); err != nil {
+		return err
+	}
+	// item_index was first added as a nullable column; fill it in before
+	// it becomes NOT NULL
+	hadItemIndex := db.Migrator().HasColumn(&models.Article{}, "item_index")
+	if hadItemIndex {
+		if err := runOnce(db, "article_item_index", backfillItemIndex); err != nil {
+			return err
+		}
+	}
+
+	if err := db.AutoMigrate(
 		&models.User{},
+		&models.APIToken{},
+		&models.UserSession{},
+		&models.AuditEvent{},
+		&models.RecoveryCode{},
+		&models.LoginLockout{},
+		&models.LoginAttempt{},
 		&models.WechatSession{},
 		&models.Account{},
+		&models.FeedToken{},
+		&models.SavedSearch{},
+		&models.WebSubSubscription{},
 		&models.Task{},
 		&models.TaskLog{},
 		&models.Article{},
+		&models.ArticleRevision{},
+		&models.UserArticleState{},
+		&models.UserArticleTag{},
 		&models.Alert{},
-	)
+		&models.AlertChannel{},
+	); err != nil {
+		return err
+	}
+
+	if !hadItemIndex {
+		if err := runOnce(db, "article_item_index", backfillItemIndex); err != nil {
+			return err
+		}
+	}
+	return runOnce(db, "purge_deleted_users", purgeDeletedUsers)
+}
+
+// purgeDeletedUsers removes users soft-deleted by earlier versions, whose
+// rows kept their usernames taken.
+func purgeDeletedUsers(tx *gorm.DB) error {
+	deleted := tx.Unscoped().Model(&models.User{}).Select("id").Where("deleted_at IS NOT NULL")
+	if err := tx.Where("user_id IN (?)", deleted).Delete(&models.UserArticleState{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("user_id IN (?)", deleted).Delete(&models.UserArticleTag{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("deleted_at IS NOT NULL").Delete(&models.User{}).Error
+}
+
+// backfillItemIndex derives item_index of articles stored before it
+// existed from the aid suffix.
+func backfillItemIndex(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE articles SET item_index = CASE
+			WHEN wechat_article_id ~ '^[0-9]+_[0-9]+$' THEN split_part(wechat_article_id, '_', 2)::int
+			ELSE 0 END
+		WHERE item_index IS NULL OR item_index = 0`).Error
+}
+
+// runOnce applies a data migration a single time, remembering it in the
+// settings table under "migration:<name>".
+func runOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	key := "migration:" + name
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Setting{}).Where("key = ?", key).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if err := migrate(tx); err != nil {
+			return err
+		}
+		return tx.Create(&models.Setting{Key: key, Value: "done"}).Error
+	})
 }
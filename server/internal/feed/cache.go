@@ -0,0 +1,140 @@
+package feed
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the number of rendered feeds kept in memory.
+const maxCacheEntries = 1024
+
+// Rendered is a serialised feed together with its validators.
+type Rendered struct {
+	Body         []byte
+	ContentType  string
+	SelfURL      string
+	ETag         string
+	LastModified time.Time
+	storedAt     time.Time
+}
+
+// RenderDocument serialises f and derives its ETag from the body and its
+// Last-Modified time from the newest item.
+func RenderDocument(f *Feed, format Format) (*Rendered, error) {
+	var buf bytes.Buffer
+	if err := Render(&buf, f, format); err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(buf.Bytes())
+	return &Rendered{
+		Body:         buf.Bytes(),
+		ContentType:  format.ContentType(),
+		SelfURL:      f.SelfURL,
+		ETag:         `"` + hex.EncodeToString(sum[:10]) + `"`,
+		LastModified: f.Updated.UTC().Truncate(time.Second),
+	}, nil
+}
+
+// Cache keeps rendered feeds in process. Entries are tagged with the
+// accounts whose articles they contain and dropped when the crawler
+// reports changes for one of them; aggregated feeds are tagged with no
+// account and dropped on any change.
+type Cache struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	entries   map[string]*Rendered
+	accounts  map[string]uint
+	aggregate map[string]bool
+}
+
+// NewCache returns a cache whose entries expire after ttl even without
+// invalidation. A non-positive ttl disables caching.
+func NewCache(ttl time.Duration) *Cache {
+	return &Cache{
+		ttl:       ttl,
+		entries:   make(map[string]*Rendered),
+		accounts:  make(map[string]uint),
+		aggregate: make(map[string]bool),
+	}
+}
+
+// Get returns the cached feed for key if present and not expired.
+func (c *Cache) Get(key string) (*Rendered, bool) {
+	if c == nil || c.ttl <= 0 {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Since(r.storedAt) > c.ttl {
+		c.remove(key)
+		return nil, false
+	}
+	return r, true
+}
+
+// Put stores r under key. accountID nil marks an aggregated feed.
+func (c *Cache) Put(key string, accountID *uint, r *Rendered) {
+	if c == nil || c.ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.entries[key]; !exists && len(c.entries) >= maxCacheEntries {
+		for k := range c.entries {
+			c.remove(k)
+			break
+		}
+	}
+	r.storedAt = time.Now()
+	c.entries[key] = r
+	if accountID == nil {
+		c.aggregate[key] = true
+		delete(c.accounts, key)
+	} else {
+		c.accounts[key] = *accountID
+		delete(c.aggregate, key)
+	}
+}
+
+// InvalidateAccount drops every cached feed that may include articles of
+// the account.
+func (c *Cache) InvalidateAccount(accountID uint) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.aggregate {
+		c.remove(key)
+	}
+	for key, id := range c.accounts {
+		if id == accountID {
+			c.remove(key)
+		}
+	}
+}
+
+// InvalidateAggregates drops every cached aggregated feed.
+func (c *Cache) InvalidateAggregates() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.aggregate {
+		c.remove(key)
+	}
+}
+
+func (c *Cache) remove(key string) {
+	delete(c.entries, key)
+	delete(c.accounts, key)
+	delete(c.aggregate, key)
+}
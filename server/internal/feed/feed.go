@@ -0,0 +1,231 @@
+package feed
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"wechat2rss/internal/models"
+)
+
+// Format selects a feed serialisation.
+type Format string
+
+const (
+	FormatRSS  Format = "rss"
+	FormatAtom Format = "atom"
+	FormatJSON Format = "json"
+)
+
+// ContentType returns the media type served for the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatAtom:
+		return "application/atom+xml; charset=utf-8"
+	case FormatJSON:
+		return "application/feed+json; charset=utf-8"
+	default:
+		return "application/rss+xml; charset=utf-8"
+	}
+}
+
+// ParseFormat maps a path extension such as "atom" or "json" to a Format.
+func ParseFormat(ext string) (Format, bool) {
+	switch strings.ToLower(ext) {
+	case "", "rss", "xml":
+		return FormatRSS, true
+	case "atom":
+		return FormatAtom, true
+	case "json":
+		return FormatJSON, true
+	}
+	return "", false
+}
+
+// Negotiate picks a format from an Accept header, defaulting to RSS.
+func Negotiate(accept string) Format {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.SplitN(part, ";", 2)[0])
+		switch mediaType {
+		case "application/atom+xml":
+			return FormatAtom
+		case "application/feed+json", "application/json":
+			return FormatJSON
+		case "application/rss+xml":
+			return FormatRSS
+		}
+	}
+	return FormatRSS
+}
+
+// Feed is a format-agnostic feed document.
+type Feed struct {
+	ID          string
+	Title       string
+	Description string
+	Link        string
+	SelfURL     string
+	Hub         string // WebSub hub advertised to subscribers, if any
+	Author      string
+	Updated     time.Time
+	Items       []Item
+
+	// Archive marks an RFC 5005 archive document; Links carries its
+	// first/next/previous or current/prev-archive/next-archive links.
+	Archive bool
+	Links   []Link
+}
+
+// Link is an additional feed-level link identified by its relation.
+type Link struct {
+	Rel  string
+	Href string
+}
+
+// Link relations of RFC 5005 feed paging and archiving.
+const (
+	RelFirst       = "first"
+	RelNext        = "next"
+	RelPrevious    = "previous"
+	RelCurrent     = "current"
+	RelPrevArchive = "prev-archive"
+	RelNextArchive = "next-archive"
+)
+
+// AddLink appends a link, ignoring empty targets.
+func (f *Feed) AddLink(rel, href string) {
+	if href != "" {
+		f.Links = append(f.Links, Link{Rel: rel, Href: href})
+	}
+}
+
+// Item is a single feed entry. ID is an IRI used by Atom and JSON Feed;
+// GUID is the bare WeChat article id RSS readers have always seen.
+type Item struct {
+	ID          string
+	GUID        string
+	Title       string
+	Link        string
+	Summary     string
+	ContentHTML string
+	Author      string
+	Image       string
+	Published   time.Time
+	Updated     time.Time
+}
+
+// FromAccount builds the feed of account from its articles, newest first.
+// selfURL is the absolute URL the feed is served from.
+func FromAccount(account *models.Account, articles []models.Article, selfURL string) *Feed {
+	f := &Feed{
+		ID:          fmt.Sprintf("tag:mp.weixin.qq.com,2013:account:%s", account.WechatID),
+		Title:       account.Name,
+		Description: account.Alias,
+		Link:        ProfileURL(account),
+		SelfURL:     selfURL,
+		Author:      account.Name,
+		Updated:     account.UpdatedAt,
+		Items:       make([]Item, 0, len(articles)),
+	}
+	for i := range articles {
+		item := itemFromArticle(&articles[i])
+		if item.Author == "" {
+			item.Author = account.Name
+		}
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+	if f.Link == "" {
+		f.Link = selfURL
+	}
+	return f
+}
+
+// Aggregate builds a feed merging articles from several accounts. Each
+// item names its source account in the title and author so readers can
+// tell them apart. accounts must contain every article's account.
+func Aggregate(id, title, description string, articles []models.Article, accounts map[uint]*models.Account, selfURL string) *Feed {
+	f := &Feed{
+		ID:          "tag:mp.weixin.qq.com,2013:aggregate:" + id,
+		Title:       title,
+		Description: description,
+		Link:        selfURL,
+		SelfURL:     selfURL,
+		Author:      "Wechat2RSS",
+		Items:       make([]Item, 0, len(articles)),
+	}
+	for i := range articles {
+		item := itemFromArticle(&articles[i])
+		if account := accounts[articles[i].AccountID]; account != nil {
+			item.Title = "[" + account.Name + "] " + item.Title
+			if item.Author != "" && item.Author != account.Name {
+				item.Author = account.Name + " · " + item.Author
+			} else {
+				item.Author = account.Name
+			}
+		}
+		if item.Updated.After(f.Updated) {
+			f.Updated = item.Updated
+		}
+		f.Items = append(f.Items, item)
+	}
+	if f.Updated.IsZero() {
+		f.Updated = time.Unix(0, 0)
+	}
+	return f
+}
+
+// ProfileURL returns the public WeChat profile page of account, or "" when
+// its biz id is unknown.
+func ProfileURL(account *models.Account) string {
+	if account.BizID == "" {
+		return ""
+	}
+	return "https://mp.weixin.qq.com/mp/profile_ext?action=home&__biz=" + url.QueryEscape(account.BizID) + "#wechat_redirect"
+}
+
+func itemFromArticle(article *models.Article) Item {
+	return Item{
+		ID:          fmt.Sprintf("tag:mp.weixin.qq.com,2013:article:%s", article.WechatArticleID),
+		GUID:        article.WechatArticleID,
+		Title:       article.Title,
+		Link:        article.RawURL,
+		Summary:     article.Summary,
+		ContentHTML: article.ContentHTML,
+		Author:      article.Author,
+		Image:       article.CoverURL,
+		Published:   article.PublishedAt,
+		Updated:     latest(article.PublishedAt, article.UpdatedAt),
+	}
+}
+
+// Render writes f to w in the requested format.
+func Render(w io.Writer, f *Feed, format Format) error {
+	switch format {
+	case FormatAtom:
+		return renderAtom(w, f)
+	case FormatJSON:
+		return renderJSON(w, f)
+	default:
+		return renderRSS(w, f)
+	}
+}
+
+func latest(a, b time.Time) time.Time {
+	if b.After(a) {
+		return b
+	}
+	return a
+}
+
+// StripContent drops the full text of every item so readers only receive
+// summaries.
+func (f *Feed) StripContent() {
+	for i := range f.Items {
+		f.Items[i].ContentHTML = ""
+	}
+}
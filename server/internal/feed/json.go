@@ -0,0 +1,109 @@
+package feed
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+const jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+
+type jsonFeed struct {
+	Version     string       `json:"version"`
+	Title       string       `json:"title"`
+	HomePageURL string       `json:"home_page_url,omitempty"`
+	FeedURL     string       `json:"feed_url,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Authors     []jsonAuthor `json:"authors,omitempty"`
+	NextURL     string       `json:"next_url,omitempty"`
+	Hubs        []jsonHub    `json:"hubs,omitempty"`
+	History     *jsonHistory `json:"_history,omitempty"`
+	Items       []jsonItem   `json:"items"`
+}
+
+// jsonHistory is a JSON Feed extension mirroring the RFC 5005 archive
+// links, which the format has no native field for.
+type jsonHistory struct {
+	About   string            `json:"about"`
+	Archive bool              `json:"archive,omitempty"`
+	Links   map[string]string `json:"links,omitempty"`
+}
+
+type jsonHub struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+type jsonAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonItem struct {
+	ID            string       `json:"id"`
+	URL           string       `json:"url,omitempty"`
+	Title         string       `json:"title,omitempty"`
+	ContentHTML   string       `json:"content_html,omitempty"`
+	ContentText   string       `json:"content_text,omitempty"`
+	Summary       string       `json:"summary,omitempty"`
+	Image         string       `json:"image,omitempty"`
+	DatePublished string       `json:"date_published,omitempty"`
+	DateModified  string       `json:"date_modified,omitempty"`
+	Authors       []jsonAuthor `json:"authors,omitempty"`
+}
+
+func renderJSON(w io.Writer, f *Feed) error {
+	doc := jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       f.Title,
+		FeedURL:     f.SelfURL,
+		Description: f.Description,
+		Items:       make([]jsonItem, 0, len(f.Items)),
+	}
+	if f.Link != f.SelfURL {
+		doc.HomePageURL = f.Link
+	}
+	if f.Author != "" {
+		doc.Authors = []jsonAuthor{{Name: f.Author}}
+	}
+	if f.Hub != "" {
+		doc.Hubs = []jsonHub{{Type: "WebSub", URL: f.Hub}}
+	}
+	if f.Archive || len(f.Links) > 0 {
+		doc.History = &jsonHistory{About: "https://www.rfc-editor.org/rfc/rfc5005", Archive: f.Archive, Links: map[string]string{}}
+		for _, link := range f.Links {
+			doc.History.Links[link.Rel] = link.Href
+			if link.Rel == RelNext {
+				doc.NextURL = link.Href
+			}
+		}
+	}
+
+	for _, item := range f.Items {
+		entry := jsonItem{
+			ID:            item.ID,
+			URL:           item.Link,
+			Title:         item.Title,
+			ContentHTML:   item.ContentHTML,
+			Summary:       item.Summary,
+			Image:         item.Image,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+		}
+		if entry.ContentHTML == "" {
+			// every item needs content_html or content_text
+			entry.ContentText = item.Summary
+			if entry.ContentText == "" {
+				entry.ContentText = item.Title
+			}
+		}
+		if item.Author != "" {
+			entry.Authors = []jsonAuthor{{Name: item.Author}}
+		}
+		doc.Items = append(doc.Items, entry)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
@@ -0,0 +1,76 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"net/url"
+	"time"
+)
+
+// OPML is an OPML 2.0 subscription list.
+type OPML struct {
+	XMLName xml.Name  `xml:"opml"`
+	Version string    `xml:"version,attr"`
+	Head    OPMLHead  `xml:"head"`
+	Body    []Outline `xml:"body>outline"`
+}
+
+// OPMLHead carries document metadata.
+type OPMLHead struct {
+	Title       string `xml:"title"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+// Outline is a folder when it has children, otherwise a subscription.
+// BizID and WechatID are Wechat2RSS extensions so lists exported here
+// round-trip without guessing.
+type Outline struct {
+	Text     string    `xml:"text,attr"`
+	Title    string    `xml:"title,attr,omitempty"`
+	Type     string    `xml:"type,attr,omitempty"`
+	XMLURL   string    `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string    `xml:"htmlUrl,attr,omitempty"`
+	BizID    string    `xml:"bizId,attr,omitempty"`
+	WechatID string    `xml:"wechatId,attr,omitempty"`
+	Children []Outline `xml:"outline"`
+}
+
+// WriteOPML serialises an OPML 2.0 document with the given outlines.
+func WriteOPML(w io.Writer, title string, body []Outline) error {
+	return encodeXML(w, OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       title,
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+		Body: body,
+	})
+}
+
+// ReadOPML parses an OPML document of any version.
+func ReadOPML(r io.Reader) (*OPML, error) {
+	var doc OPML
+	dec := xml.NewDecoder(r)
+	dec.Strict = false
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// BizFromURL extracts the __biz query parameter WeChat uses to identify a
+// public account, returning "" when rawURL has none.
+func BizFromURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	if biz := u.Query().Get("__biz"); biz != "" {
+		return biz
+	}
+	// profile links put the query after a #fragment in some exports
+	if frag, err := url.ParseQuery(u.Fragment); err == nil {
+		return frag.Get("__biz")
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package feed
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"wechat2rss/internal/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+var formats = []Format{FormatRSS, FormatAtom, FormatJSON}
+
+func fixtureAccount() *models.Account {
+	return &models.Account{
+		ID:        1,
+		Name:      "测试公众号",
+		Alias:     "gh_test & friends",
+		WechatID:  "gh_123456",
+		BizID:     "MzA5NjQ4MjE0MA==",
+		UpdatedAt: time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func fixtureArticles() []models.Article {
+	published := time.Date(2024, 3, 5, 12, 30, 0, 0, time.UTC)
+	return []models.Article{
+		{
+			ID:              2,
+			AccountID:       1,
+			WechatArticleID: "2247483650_1",
+			Title:           "标题 <二> & \"引号\"",
+			Author:          "作者甲",
+			Summary:         "第二篇的摘要",
+			ContentHTML:     `<p>正文 <img src="https://mmbiz.qpic.cn/a.jpg"> ]]> 结束</p>`,
+			RawURL:          "https://mp.weixin.qq.com/s?__biz=MzA5NjQ4MjE0MA==&mid=2247483650&idx=1",
+			CoverURL:        "https://mmbiz.qpic.cn/cover.png",
+			PublishedAt:     published,
+			UpdatedAt:       published.Add(2 * time.Hour),
+		},
+		{
+			ID:              1,
+			AccountID:       1,
+			WechatArticleID: "2247483649_2",
+			Title:           "第一篇",
+			Summary:         "第一篇的摘要",
+			RawURL:          "https://mp.weixin.qq.com/s?__biz=MzA5NjQ4MjE0MA==&mid=2247483649&idx=2",
+			PublishedAt:     published.AddDate(0, 0, -3),
+			UpdatedAt:       published.AddDate(0, 0, -3),
+		},
+	}
+}
+
+// TestRenderGolden renders the account feed, a paged and an archive
+// document and an aggregate feed in every format and compares them with
+// testdata/*.golden. Run with -update after intended changes.
+func TestRenderGolden(t *testing.T) {
+	const base = "https://rss.example.com/feed/1"
+	cases := map[string]func() *Feed{
+		"account": func() *Feed {
+			f := FromAccount(fixtureAccount(), fixtureArticles(), base)
+			f.Hub = "https://rss.example.com/websub"
+			return f
+		},
+		"page": func() *Feed {
+			f := FromAccount(fixtureAccount(), fixtureArticles()[1:], base+"?page=2")
+			f.AddLink(RelFirst, base)
+			f.AddLink(RelPrevious, base)
+			f.AddLink(RelNext, base+"?page=3")
+			return f
+		},
+		"archive": func() *Feed {
+			f := FromAccount(fixtureAccount(), fixtureArticles(), base+"/archive/2024-03")
+			f.StripContent()
+			f.Archive = true
+			f.AddLink(RelCurrent, base)
+			f.AddLink(RelPrevArchive, base+"/archive/2024-02")
+			return f
+		},
+		"aggregate": func() *Feed {
+			accounts := map[uint]*models.Account{1: fixtureAccount()}
+			return Aggregate("group:科技", "分组「科技」", "分组「科技」的最新文章", fixtureArticles(), accounts,
+				"https://rss.example.com/feed/group/%E7%A7%91%E6%8A%80")
+		},
+	}
+
+	for name, build := range cases {
+		for _, format := range formats {
+			t.Run(name+"."+string(format), func(t *testing.T) {
+				var buf bytes.Buffer
+				if err := Render(&buf, build(), format); err != nil {
+					t.Fatal(err)
+				}
+				golden := filepath.Join("testdata", name+"."+string(format)+".golden")
+				if *update {
+					if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := os.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(buf.Bytes(), want) {
+					t.Errorf("%s differs from golden file:\n%s", golden, buf.String())
+				}
+			})
+		}
+	}
+}
+
+func TestRenderDocumentETag(t *testing.T) {
+	f := FromAccount(fixtureAccount(), fixtureArticles(), "https://rss.example.com/feed/1")
+	a, err := RenderDocument(f, FormatRSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := RenderDocument(f, FormatRSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.ETag != b.ETag || a.ETag == "" {
+		t.Errorf("ETag not stable: %q vs %q", a.ETag, b.ETag)
+	}
+	f.Items[0].ContentHTML = ""
+	c, err := RenderDocument(f, FormatRSS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ETag == a.ETag {
+		t.Error("ETag did not change with the content")
+	}
+	if !a.LastModified.Equal(time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)) {
+		t.Errorf("LastModified = %v", a.LastModified)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]Format{
+		"":                                      FormatRSS,
+		"text/html, application/atom+xml;q=0.9": FormatAtom,
+		"application/feed+json":                 FormatJSON,
+		"application/json":                      FormatJSON,
+		"application/rss+xml, application/atom+xml": FormatRSS,
+	}
+	for accept, want := range cases {
+		if got := Negotiate(accept); got != want {
+			t.Errorf("Negotiate(%q) = %s, want %s", accept, got, want)
+		}
+	}
+}
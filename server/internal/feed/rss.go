@@ -0,0 +1,166 @@
+package feed
+
+import (
+	"encoding/xml"
+	"io"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	nsContent = "http://purl.org/rss/1.0/modules/content/"
+	nsDC      = "http://purl.org/dc/elements/1.1/"
+	nsMedia   = "http://search.yahoo.com/mrss/"
+	nsAtom    = "http://www.w3.org/2005/Atom"
+	nsHistory = "http://purl.org/syndication/history/1.0"
+)
+
+type rssFeed struct {
+	XMLName   xml.Name   `xml:"rss"`
+	Version   string     `xml:"version,attr"`
+	NSContent string     `xml:"xmlns:content,attr"`
+	NSDC      string     `xml:"xmlns:dc,attr"`
+	NSMedia   string     `xml:"xmlns:media,attr"`
+	NSAtom    string     `xml:"xmlns:atom,attr"`
+	NSHistory string     `xml:"xmlns:fh,attr,omitempty"`
+	Channel   rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string       `xml:"title"`
+	Link          string       `xml:"link"`
+	Description   string       `xml:"description"`
+	LastBuildDate string       `xml:"lastBuildDate"`
+	Generator     string       `xml:"generator"`
+	AtomLinks     []rssAtomRef `xml:"atom:link"`
+	Archive       *struct{}    `xml:"fh:archive"`
+	Items         []rssItem    `xml:"item"`
+}
+
+type rssAtomRef struct {
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link,omitempty"`
+	Description string        `xml:"description"`
+	Creator     string        `xml:"dc:creator,omitempty"`
+	PubDate     string        `xml:"pubDate"`
+	GUID        rssGUID       `xml:"guid"`
+	Enclosure   *rssEnclosure `xml:"enclosure,omitempty"`
+	Thumbnail   *rssThumbnail `xml:"media:thumbnail,omitempty"`
+	Content     *rssCDATA     `xml:"content:encoded,omitempty"`
+}
+
+type rssGUID struct {
+	IsPermaLink string `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Length string `xml:"length,attr"`
+	Type   string `xml:"type,attr"`
+}
+
+type rssThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+type rssCDATA struct {
+	Value string `xml:",cdata"`
+}
+
+func renderRSS(w io.Writer, f *Feed) error {
+	items := make([]rssItem, 0, len(f.Items))
+	for _, item := range f.Items {
+		entry := rssItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Description: item.Summary,
+			Creator:     item.Author,
+			PubDate:     item.Published.Format(time.RFC1123Z),
+			GUID:        rssGUID{IsPermaLink: "false", Value: item.GUID},
+		}
+		if item.ContentHTML != "" {
+			entry.Content = &rssCDATA{Value: item.ContentHTML}
+		}
+		if item.Image != "" {
+			// the length of remote covers is unknown; RSS readers accept 0
+			entry.Enclosure = &rssEnclosure{URL: item.Image, Length: "0", Type: imageType(item.Image)}
+			entry.Thumbnail = &rssThumbnail{URL: item.Image}
+		}
+		items = append(items, entry)
+	}
+
+	description := f.Description
+	if description == "" {
+		description = f.Title
+	}
+	doc := rssFeed{
+		Version:   "2.0",
+		NSContent: nsContent,
+		NSDC:      nsDC,
+		NSMedia:   nsMedia,
+		NSAtom:    nsAtom,
+		Channel: rssChannel{
+			Title:         f.Title,
+			Link:          f.Link,
+			Description:   description,
+			LastBuildDate: f.Updated.Format(time.RFC1123Z),
+			Generator:     "Wechat2RSS",
+			AtomLinks: []rssAtomRef{
+				{Rel: "self", Type: "application/rss+xml", Href: f.SelfURL},
+			},
+			Items: items,
+		},
+	}
+	if f.Hub != "" {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, rssAtomRef{Rel: "hub", Href: f.Hub})
+	}
+	for _, link := range f.Links {
+		doc.Channel.AtomLinks = append(doc.Channel.AtomLinks, rssAtomRef{Rel: link.Rel, Href: link.Href})
+	}
+	if f.Archive {
+		doc.NSHistory = nsHistory
+		doc.Channel.Archive = &struct{}{}
+	}
+	return encodeXML(w, doc)
+}
+
+// imageType guesses the media type of a cover image. WeChat CDN URLs carry
+// the format in the wx_fmt query parameter rather than an extension.
+func imageType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "image/jpeg"
+	}
+	if format := u.Query().Get("wx_fmt"); format != "" {
+		if format == "jpg" {
+			format = "jpeg"
+		}
+		return "image/" + strings.ToLower(format)
+	}
+	if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+		return t
+	}
+	return "image/jpeg"
+}
+
+func encodeXML(w io.Writer, doc any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -11,12 +11,45 @@ import (
 )
 
 type accountRequest struct {
-	Name      string `json:"name" binding:"required"`
-	WechatID  string `json:"wechat_id" binding:"required"`
-	BizID     string `json:"biz_id"`
-	Alias     string `json:"alias"`
-	Status    string `json:"status"`
-	SessionID *uint  `json:"session_id"`
+	Name        string           `json:"name" binding:"required"`
+	WechatID    string           `json:"wechat_id" binding:"required"`
+	BizID       string           `json:"biz_id"`
+	Alias       string           `json:"alias"`
+	Group       string           `json:"group"`
+	Status      string           `json:"status"`
+	FeedPrivate bool             `json:"feed_private"`
+	FeedOptions *feedOptionsView `json:"feed_options"`
+	SessionID   *uint            `json:"session_id"`
+}
+
+// feedOptionsView exposes models.FeedOptions; omitting it from an
+// account request keeps the saved defaults.
+type feedOptionsView struct {
+	Limit       int    `json:"limit"`
+	SummaryOnly bool   `json:"summary_only"`
+	Include     string `json:"include"`
+	Exclude     string `json:"exclude"`
+	FirstOnly   bool   `json:"first_only"`
+}
+
+func (v *feedOptionsView) model() models.FeedOptions {
+	return models.FeedOptions{
+		Limit:       v.Limit,
+		SummaryOnly: v.SummaryOnly,
+		Include:     v.Include,
+		Exclude:     v.Exclude,
+		FirstOnly:   v.FirstOnly,
+	}
+}
+
+func toFeedOptionsView(o models.FeedOptions) feedOptionsView {
+	return feedOptionsView{
+		Limit:       o.Limit,
+		SummaryOnly: o.SummaryOnly,
+		Include:     o.Include,
+		Exclude:     o.Exclude,
+		FirstOnly:   o.FirstOnly,
+	}
 }
 
 func (s *Server) handleCreateAccount(c *gin.Context) {
@@ -27,12 +60,21 @@ func (s *Server) handleCreateAccount(c *gin.Context) {
 	}
 
 	account := models.Account{
-		Name:      req.Name,
-		WechatID:  req.WechatID,
-		BizID:     req.BizID,
-		Alias:     req.Alias,
-		Status:    defaultStatus(req.Status),
-		SessionID: req.SessionID,
+		Name:        req.Name,
+		WechatID:    req.WechatID,
+		BizID:       req.BizID,
+		Alias:       req.Alias,
+		GroupName:   req.Group,
+		Status:      defaultStatus(req.Status),
+		FeedPrivate: req.FeedPrivate,
+		SessionID:   req.SessionID,
+	}
+	if req.FeedOptions != nil {
+		account.FeedOptions = req.FeedOptions.model()
+		if err := validateFeedOptions(account.FeedOptions); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
 	}
 
 	if err := s.db.Create(&account).Error; err != nil {
@@ -40,7 +82,9 @@ func (s *Server) handleCreateAccount(c *gin.Context) {
 		return
 	}
 
-	respondOK(c, apiData{"account": toAccountView(&account)})
+	view := toAccountView(&account)
+	auditChange(c, account.ID, nil, view)
+	respondOK(c, apiData{"account": view})
 }
 
 func (s *Server) handleListAccounts(c *gin.Context) {
@@ -49,9 +93,17 @@ func (s *Server) handleListAccounts(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "failed to list accounts")
 		return
 	}
+	unread, err := s.unreadCounts(sessionUserID(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to count unread articles")
+		return
+	}
 	var result []accountView
 	for i := range accounts {
-		result = append(result, *toAccountView(&accounts[i]))
+		view := toAccountView(&accounts[i])
+		count := unread[accounts[i].ID].Count
+		view.UnreadCount = &count
+		result = append(result, *view)
 	}
 	respondOK(c, apiData{"accounts": result})
 }
@@ -77,20 +129,33 @@ func (s *Server) handleUpdateAccount(c *gin.Context) {
 		respondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	before := toAccountView(account)
 
 	account.Name = req.Name
 	account.WechatID = req.WechatID
 	account.BizID = req.BizID
 	account.Alias = req.Alias
+	account.GroupName = req.Group
 	account.Status = defaultStatus(req.Status)
+	account.FeedPrivate = req.FeedPrivate
 	account.SessionID = req.SessionID
+	if req.FeedOptions != nil {
+		account.FeedOptions = req.FeedOptions.model()
+		if err := validateFeedOptions(account.FeedOptions); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
 
 	if err := s.db.Save(account).Error; err != nil {
 		respondError(c, http.StatusInternalServerError, "failed to update account")
 		return
 	}
+	s.feeds.InvalidateAccount(account.ID)
 
-	respondOK(c, apiData{"account": toAccountView(account)})
+	view := toAccountView(account)
+	auditChange(c, account.ID, before, view)
+	respondOK(c, apiData{"account": view})
 }
 
 func (s *Server) handleDeleteAccount(c *gin.Context) {
@@ -104,6 +169,8 @@ func (s *Server) handleDeleteAccount(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "failed to delete account")
 		return
 	}
+	s.feeds.InvalidateAccount(account.ID)
+	auditChange(c, account.ID, toAccountView(account), nil)
 
 	respondOK(c, apiData{"deleted": account.ID})
 }
@@ -128,29 +195,36 @@ func defaultStatus(value string) string {
 }
 
 type accountView struct {
-	ID         uint      `json:"id"`
-	Name       string    `json:"name"`
-	WechatID   string    `json:"wechat_id"`
-	BizID      string    `json:"biz_id"`
-	Alias      string    `json:"alias"`
-	Status     string    `json:"status"`
-	SessionID  *uint     `json:"session_id"`
-	LastTaskID *uint     `json:"last_task_id"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+	ID          uint            `json:"id"`
+	Name        string          `json:"name"`
+	WechatID    string          `json:"wechat_id"`
+	BizID       string          `json:"biz_id"`
+	Alias       string          `json:"alias"`
+	Group       string          `json:"group"`
+	Status      string          `json:"status"`
+	FeedPrivate bool            `json:"feed_private"`
+	FeedOptions feedOptionsView `json:"feed_options"`
+	SessionID   *uint           `json:"session_id"`
+	LastTaskID  *uint           `json:"last_task_id"`
+	UnreadCount *int            `json:"unread_count,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
 }
 
 func toAccountView(a *models.Account) *accountView {
 	return &accountView{
-		ID:         a.ID,
-		Name:       a.Name,
-		WechatID:   a.WechatID,
-		BizID:      a.BizID,
-		Alias:      a.Alias,
-		Status:     a.Status,
-		SessionID:  a.SessionID,
-		LastTaskID: a.LastTaskID,
-		CreatedAt:  a.CreatedAt,
-		UpdatedAt:  a.UpdatedAt,
+		ID:          a.ID,
+		Name:        a.Name,
+		WechatID:    a.WechatID,
+		BizID:       a.BizID,
+		Alias:       a.Alias,
+		Group:       a.GroupName,
+		Status:      a.Status,
+		FeedPrivate: a.FeedPrivate,
+		FeedOptions: toFeedOptionsView(a.FeedOptions),
+		SessionID:   a.SessionID,
+		LastTaskID:  a.LastTaskID,
+		CreatedAt:   a.CreatedAt,
+		UpdatedAt:   a.UpdatedAt,
 	}
 }
@@ -0,0 +1,199 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/alert"
+	"wechat2rss/internal/models"
+)
+
+type alertChannelRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Kind   string `json:"kind" binding:"required,oneof=webhook email wecom feishu dingtalk slack"`
+	Target string `json:"target" binding:"required"`
+	// Secret is kept when omitted on update; "" clears it.
+	Secret         *string  `json:"secret"`
+	Types          []string `json:"types"`
+	MinSeverity    string   `json:"min_severity" binding:"omitempty,oneof=warning critical"`
+	NotifyResolved *bool    `json:"notify_resolved"`
+	Enabled        *bool    `json:"enabled"`
+}
+
+type alertChannelView struct {
+	ID             uint       `json:"id"`
+	Name           string     `json:"name"`
+	Kind           string     `json:"kind"`
+	Target         string     `json:"target"`
+	HasSecret      bool       `json:"has_secret"`
+	Types          []string   `json:"types"`
+	MinSeverity    string     `json:"min_severity"`
+	NotifyResolved bool       `json:"notify_resolved"`
+	Enabled        bool       `json:"enabled"`
+	LastSentAt     *time.Time `json:"last_sent_at"`
+	LastError      string     `json:"last_error"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+func toAlertChannelView(ch *models.AlertChannel) alertChannelView {
+	types := []string{}
+	if ch.Types != "" {
+		types = strings.Split(ch.Types, ",")
+	}
+	return alertChannelView{
+		ID:             ch.ID,
+		Name:           ch.Name,
+		Kind:           ch.Kind,
+		Target:         ch.Target,
+		HasSecret:      ch.Secret != "",
+		Types:          types,
+		MinSeverity:    ch.MinSeverity,
+		NotifyResolved: ch.NotifyResolved,
+		Enabled:        ch.Enabled,
+		LastSentAt:     ch.LastSentAt,
+		LastError:      ch.LastError,
+		CreatedAt:      ch.CreatedAt,
+	}
+}
+
+// alertChannelAudit is the channel as recorded in the audit log. Robot
+// URLs carry their access key, so only the host is kept.
+func alertChannelAudit(ch *models.AlertChannel) alertChannelView {
+	view := toAlertChannelView(ch)
+	if ch.Kind != models.AlertChannelEmail {
+		if u, err := url.Parse(ch.Target); err == nil {
+			view.Target = u.Scheme + "://" + u.Host
+		}
+	}
+	view.LastSentAt = nil
+	view.LastError = ""
+	return view
+}
+
+func (s *Server) handleListAlertChannels(c *gin.Context) {
+	var channels []models.AlertChannel
+	if err := s.db.Order("id").Find(&channels).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list alert channels")
+		return
+	}
+	result := make([]alertChannelView, 0, len(channels))
+	for i := range channels {
+		result = append(result, toAlertChannelView(&channels[i]))
+	}
+	respondOK(c, apiData{"channels": result, "alert_types": alert.Types})
+}
+
+func (s *Server) handleCreateAlertChannel(c *gin.Context) {
+	var req alertChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	ch := models.AlertChannel{NotifyResolved: true, Enabled: true}
+	if err := s.applyAlertChannel(&ch, &req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.db.Create(&ch).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create alert channel")
+		return
+	}
+	auditChange(c, ch.ID, nil, alertChannelAudit(&ch))
+	respondOK(c, apiData{"channel": toAlertChannelView(&ch)})
+}
+
+func (s *Server) handleUpdateAlertChannel(c *gin.Context) {
+	ch, err := s.findAlertChannel(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "alert channel not found")
+		return
+	}
+	var req alertChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	before := alertChannelAudit(ch)
+	if err := s.applyAlertChannel(ch, &req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.db.Save(ch).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update alert channel")
+		return
+	}
+	auditChange(c, ch.ID, before, alertChannelAudit(ch))
+	respondOK(c, apiData{"channel": toAlertChannelView(ch)})
+}
+
+func (s *Server) handleDeleteAlertChannel(c *gin.Context) {
+	ch, err := s.findAlertChannel(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "alert channel not found")
+		return
+	}
+	if err := s.db.Delete(ch).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete alert channel")
+		return
+	}
+	auditChange(c, ch.ID, alertChannelAudit(ch), nil)
+	respondOK(c, apiData{"deleted": ch.ID})
+}
+
+// handleTestAlertChannel sends a sample notification through the channel
+// and reports the delivery error, if any.
+func (s *Server) handleTestAlertChannel(c *gin.Context) {
+	ch, err := s.findAlertChannel(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "alert channel not found")
+		return
+	}
+	if err := alert.SendTest(c.Request.Context(), s.cfg, ch); err != nil {
+		respondError(c, http.StatusBadGateway, "test notification failed: "+err.Error())
+		return
+	}
+	respondOK(c, apiData{"sent": true})
+}
+
+// applyAlertChannel copies a validated request onto ch.
+func (s *Server) applyAlertChannel(ch *models.AlertChannel, req *alertChannelRequest) error {
+	for _, typ := range req.Types {
+		if !slices.Contains(alert.Types, typ) {
+			return fmt.Errorf("unknown alert type %s", typ)
+		}
+	}
+	ch.Name = req.Name
+	ch.Kind = req.Kind
+	ch.Target = strings.TrimSpace(req.Target)
+	if req.Secret != nil {
+		ch.Secret = *req.Secret
+	}
+	ch.Types = strings.Join(req.Types, ",")
+	ch.MinSeverity = req.MinSeverity
+	if req.NotifyResolved != nil {
+		ch.NotifyResolved = *req.NotifyResolved
+	}
+	if req.Enabled != nil {
+		ch.Enabled = *req.Enabled
+	}
+	return alert.ValidateChannel(s.cfg, ch)
+}
+
+func (s *Server) findAlertChannel(idParam string) (*models.AlertChannel, error) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, err
+	}
+	var ch models.AlertChannel
+	if err := s.db.First(&ch, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &ch, nil
+}
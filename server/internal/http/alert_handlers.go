@@ -0,0 +1,145 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+)
+
+type alertView struct {
+	ID             uint            `json:"id"`
+	Type           string          `json:"type"`
+	Status         string          `json:"status"`
+	Severity       string          `json:"severity"`
+	Message        string          `json:"message"`
+	AccountID      *uint           `json:"account_id"`
+	SessionID      *uint           `json:"session_id"`
+	Payload        json.RawMessage `json:"payload,omitempty"`
+	LastSeenAt     time.Time       `json:"last_seen_at"`
+	AcknowledgedAt *time.Time      `json:"acknowledged_at"`
+	AcknowledgedBy *uint           `json:"acknowledged_by"`
+	ResolvedAt     *time.Time      `json:"resolved_at"`
+	NotifiedAt     *time.Time      `json:"notified_at"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+func toAlertView(a *models.Alert) alertView {
+	view := alertView{
+		ID:             a.ID,
+		Type:           a.Type,
+		Status:         a.Status,
+		Severity:       a.Severity,
+		Message:        a.Message,
+		AccountID:      a.AccountID,
+		SessionID:      a.SessionID,
+		LastSeenAt:     a.LastSeenAt,
+		AcknowledgedAt: a.AcknowledgedAt,
+		AcknowledgedBy: a.AcknowledgedBy,
+		ResolvedAt:     a.ResolvedAt,
+		NotifiedAt:     a.NotifiedAt,
+		CreatedAt:      a.CreatedAt,
+	}
+	if a.Payload != "" {
+		view.Payload = json.RawMessage(a.Payload)
+	}
+	return view
+}
+
+// handleListAlerts lists alerts newest first. By default only unresolved
+// alerts are returned; status=open,acknowledged,resolved or status=all
+// widens the list. type and account_id filter further, and pages are
+// continued with before=<next_before>.
+func (s *Server) handleListAlerts(c *gin.Context) {
+	query := s.db.Model(&models.Alert{})
+	switch status := c.Query("status"); status {
+	case "":
+		query = query.Where("status <> ?", models.AlertStatusResolved)
+	case "all":
+	default:
+		query = query.Where("status IN ?", strings.Split(status, ","))
+	}
+	if v := c.Query("type"); v != "" {
+		query = query.Where("type IN ?", strings.Split(v, ","))
+	}
+	if v := c.Query("account_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid account_id")
+			return
+		}
+		query = query.Where("account_id = ?", id)
+	}
+	if v := c.Query("before"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before")
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	limit := pageSize(c.Query("limit"))
+	var alerts []models.Alert
+	if err := query.Order("id DESC").Limit(limit).Find(&alerts).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list alerts")
+		return
+	}
+	var open int64
+	if err := s.db.Model(&models.Alert{}).Where("status = ?", models.AlertStatusOpen).Count(&open).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to count alerts")
+		return
+	}
+
+	result := make([]alertView, 0, len(alerts))
+	for i := range alerts {
+		result = append(result, toAlertView(&alerts[i]))
+	}
+	data := apiData{"alerts": result, "open_count": open}
+	if len(alerts) == limit {
+		data["next_before"] = alerts[len(alerts)-1].ID
+	}
+	respondOK(c, data)
+}
+
+type alertActionRequest struct {
+	IDs    []uint `json:"ids" binding:"required,min=1"`
+	Action string `json:"action" binding:"required,oneof=ack resolve"`
+}
+
+// handleUpdateAlerts acknowledges or resolves alerts in bulk. Resolved
+// alerts are left alone; an alert resolved by hand whose condition persists
+// is raised again on the next evaluation.
+func (s *Server) handleUpdateAlerts(c *gin.Context) {
+	var req alertActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	now := time.Now()
+	updates := map[string]any{
+		"status":      models.AlertStatusResolved,
+		"resolved_at": now,
+	}
+	query := s.db.Model(&models.Alert{}).Where("id IN ? AND status <> ?", req.IDs, models.AlertStatusResolved)
+	if req.Action == "ack" {
+		updates = map[string]any{
+			"status":          models.AlertStatusAcknowledged,
+			"acknowledged_at": now,
+			"acknowledged_by": sessionUserID(c),
+		}
+		query = query.Where("status = ?", models.AlertStatusOpen)
+	}
+	result := query.Updates(updates)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update alerts")
+		return
+	}
+	respondOK(c, apiData{"updated": result.RowsAffected})
+}
@@ -0,0 +1,196 @@
+package http
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/util"
+)
+
+const (
+	apiTokenPrefix = "w2r_"
+	// apiTokenTouchInterval limits last-used bookkeeping to one write per
+	// token and interval.
+	apiTokenTouchInterval = time.Minute
+)
+
+var (
+	errInvalidToken = errors.New("invalid token")
+	errTokenScope   = errors.New("token scope does not allow this request")
+)
+
+var validScopes = []string{models.ScopeRead, models.ScopeTasks, models.ScopeFull}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// authenticateAPIToken resolves a Bearer token to its user.
+func (s *Server) authenticateAPIToken(c *gin.Context, value string) (*models.User, *models.APIToken, error) {
+	var token models.APIToken
+	if err := s.db.First(&token, "token_hash = ?", hashAPIToken(value)).Error; err != nil {
+		return nil, nil, errInvalidToken
+	}
+	if token.ExpiresAt != nil && token.ExpiresAt.Before(time.Now()) {
+		return nil, nil, errInvalidToken
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", token.UserID).Error; err != nil || user.Disabled {
+		return nil, nil, errInvalidToken
+	}
+
+	if token.LastUsedAt == nil || time.Since(*token.LastUsedAt) > apiTokenTouchInterval || token.LastUsedIP != c.ClientIP() {
+		if err := s.db.Model(&token).Updates(map[string]any{
+			"last_used_at": time.Now(),
+			"last_used_ip": c.ClientIP(),
+		}).Error; err != nil {
+			log.Printf("api token %d touch error: %v", token.ID, err)
+		}
+	}
+	return &user, &token, nil
+}
+
+// tokenAllows checks a request against the token's scopes: read allows
+// safe methods, tasks allows enqueueing crawl tasks, full allows anything
+// the owner's role does.
+func tokenAllows(token *models.APIToken, c *gin.Context) bool {
+	scopes := strings.Split(token.Scopes, ",")
+	if slices.Contains(scopes, models.ScopeFull) {
+		return true
+	}
+	method := c.Request.Method
+	if slices.Contains(scopes, models.ScopeRead) && (method == http.MethodGet || method == http.MethodHead) {
+		return true
+	}
+	if slices.Contains(scopes, models.ScopeTasks) && method == http.MethodPost && c.FullPath() == "/api/accounts/:id/tasks" {
+		return true
+	}
+	return false
+}
+
+// requireSessionAuth rejects requests authenticated by API token, so a
+// leaked token cannot mint further tokens.
+func (s *Server) requireSessionAuth(c *gin.Context) {
+	if _, ok := c.Get("api_token"); ok {
+		respondError(c, http.StatusForbidden, "this endpoint requires a login session")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+type apiTokenView struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `json:"last_used_ip"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func toAPITokenView(t *models.APIToken) apiTokenView {
+	return apiTokenView{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     strings.Split(t.Scopes, ","),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		LastUsedIP: t.LastUsedIP,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+func (s *Server) handleListAPITokens(c *gin.Context) {
+	var tokens []models.APIToken
+	if err := s.db.Where("user_id = ?", sessionUserID(c)).Order("id desc").Find(&tokens).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list tokens")
+		return
+	}
+	result := make([]apiTokenView, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, toAPITokenView(&tokens[i]))
+	}
+	respondOK(c, apiData{"tokens": result})
+}
+
+type apiTokenRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// handleCreateAPIToken returns the token in plain text; it cannot be
+// retrieved again.
+func (s *Server) handleCreateAPIToken(c *gin.Context) {
+	var req apiTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(validScopes, scope) {
+			respondError(c, http.StatusBadRequest, "invalid scope "+scope)
+			return
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		respondError(c, http.StatusBadRequest, "invalid expires_in_days")
+		return
+	}
+
+	secret, err := util.RandHex(24)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	value := apiTokenPrefix + secret
+	token := models.APIToken{
+		UserID:    sessionUserID(c),
+		Name:      req.Name,
+		Prefix:    value[:len(apiTokenPrefix)+6],
+		TokenHash: hashAPIToken(value),
+		Scopes:    strings.Join(req.Scopes, ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expires
+	}
+	if err := s.db.Create(&token).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create token")
+		return
+	}
+	auditChange(c, token.ID, nil, toAPITokenView(&token))
+	respondOK(c, apiData{"token": toAPITokenView(&token), "secret": value})
+}
+
+func (s *Server) handleDeleteAPIToken(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "token not found")
+		return
+	}
+	result := s.db.Where("id = ? AND user_id = ?", id, sessionUserID(c)).Delete(&models.APIToken{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete token")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, "token not found")
+		return
+	}
+	respondOK(c, apiData{"deleted": id})
+}
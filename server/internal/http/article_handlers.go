@@ -1,13 +1,16 @@
 package http
 
 import (
-	"encoding/xml"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 
+	"wechat2rss/internal/content"
 	"wechat2rss/internal/models"
 )
 
@@ -27,81 +30,331 @@ func (s *Server) handleListArticles(c *gin.Context) {
 		return
 	}
 
+	views, err := s.articleViews(c, articles)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load articles")
+		return
+	}
 	respondOK(c, apiData{
 		"account":  toAccountView(account),
-		"articles": articles,
+		"articles": views,
 	})
 }
 
-type rssFeed struct {
-	XMLName xml.Name   `xml:"rss"`
-	Version string     `xml:"version,attr"`
-	Channel rssChannel `xml:"channel"`
+func (s *Server) handleListAllArticles(c *gin.Context) {
+	cursor, err := decodeArticleCursor(c.Query("cursor"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := pageSize(c.Query("limit"))
+
+	var asc bool
+	switch c.DefaultQuery("order", "desc") {
+	case "desc":
+	case "asc":
+		asc = true
+	default:
+		respondError(c, http.StatusBadRequest, "order must be asc or desc")
+		return
+	}
+
+	query, err := s.filterArticles(c, s.db.Model(&models.Article{}))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		query = s.search.Match(query, q)
+	}
+	if c.Query("include_content") != "true" {
+		query = query.Omit("content_html", "content_text", "content_markdown")
+	}
+
+	articles, nextCursor, err := findArticlePage(query, cursor, asc, limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load articles")
+		return
+	}
+
+	views, err := s.articleViews(c, articles)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load articles")
+		return
+	}
+	respondOK(c, apiData{
+		"articles":    views,
+		"next_cursor": nextCursor,
+	})
+}
+
+// filterArticles applies the listing filters shared by the article list
+// and search endpoints.
+func (s *Server) filterArticles(c *gin.Context, query *gorm.DB) (*gorm.DB, error) {
+	if ids := c.Query("account_id"); ids != "" {
+		var accountIDs []int
+		for _, raw := range strings.Split(ids, ",") {
+			id, err := strconv.Atoi(raw)
+			if err != nil {
+				return nil, errors.New("invalid account_id")
+			}
+			accountIDs = append(accountIDs, id)
+		}
+		query = query.Where("articles.account_id IN ?", accountIDs)
+	}
+	if group := c.Query("group"); group != "" {
+		query = query.Where("articles.account_id IN (?)",
+			s.db.Model(&models.Account{}).Select("id").Where("group_name = ?", group))
+	}
+	if from := c.Query("from"); from != "" {
+		t, err := parseDate(from)
+		if err != nil {
+			return nil, errors.New("invalid from")
+		}
+		query = query.Where("articles.published_at >= ?", t)
+	}
+	if to := c.Query("to"); to != "" {
+		t, err := parseDate(to)
+		if err != nil {
+			return nil, errors.New("invalid to")
+		}
+		if len(to) == len("2006-01-02") {
+			// a bare date includes the whole day
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("articles.published_at < ?", t)
+	}
+	if author := c.Query("author"); author != "" {
+		query = query.Where("articles.author = ?", author)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("articles.content_status IN ?", strings.Split(status, ","))
+	}
+	switch c.Query("has_content") {
+	case "":
+	case "true":
+		query = query.Where("articles.content_html <> ''")
+	case "false":
+		query = query.Where("(articles.content_html IS NULL OR articles.content_html = '')")
+	default:
+		return nil, errors.New("has_content must be true or false")
+	}
+
+	userID := sessionUserID(c)
+	for _, flag := range []string{stateRead, stateStarred, stateArchived} {
+		switch c.Query(flag) {
+		case "":
+		case "true":
+			query = withState(query, userID, flag, true)
+		case "false":
+			query = withState(query, userID, flag, false)
+		default:
+			return nil, errors.New(flag + " must be true or false")
+		}
+	}
+	if tag := c.Query("tag"); tag != "" {
+		query = query.Where("articles.id IN (?)", s.db.Model(&models.UserArticleTag{}).
+			Select("article_id").Where("user_id = ? AND tag = ?", userID, tag))
+	}
+	return query, nil
 }
 
-type rssChannel struct {
-	Title         string    `xml:"title"`
-	Link          string    `xml:"link"`
-	Description   string    `xml:"description"`
-	LastBuildDate string    `xml:"lastBuildDate"`
-	Items         []rssItem `xml:"item"`
+type articleView struct {
+	ID              uint       `json:"id"`
+	AccountID       uint       `json:"account_id"`
+	WechatArticleID string     `json:"wechat_article_id"`
+	Title           string     `json:"title"`
+	Author          string     `json:"author"`
+	Summary         string     `json:"summary"`
+	CoverURL        string     `json:"cover_url"`
+	ContentHTML     string     `json:"content_html"`
+	RawURL          string     `json:"raw_url"`
+	PublishedAt     time.Time  `json:"published_at"`
+	WordCount       int        `json:"word_count"`
+	ImageCount      int        `json:"image_count"`
+	ContentStatus   string     `json:"content_status"`
+	RemovedAt       *time.Time `json:"removed_at"`
+	CheckedAt       *time.Time `json:"checked_at"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+
+	State *articleStateView `json:"state,omitempty"`
 }
 
-type rssItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-	GUID        string `xml:"guid"`
-	Content     string `xml:"encoded,omitempty"`
+func toArticleView(a *models.Article) articleView {
+	return articleView{
+		ID:              a.ID,
+		AccountID:       a.AccountID,
+		WechatArticleID: a.WechatArticleID,
+		Title:           a.Title,
+		Author:          a.Author,
+		Summary:         a.Summary,
+		CoverURL:        a.CoverURL,
+		ContentHTML:     a.ContentHTML,
+		RawURL:          a.RawURL,
+		PublishedAt:     a.PublishedAt,
+		WordCount:       a.WordCount,
+		ImageCount:      a.ImageCount,
+		ContentStatus:   a.ContentStatus,
+		RemovedAt:       a.RemovedAt,
+		CheckedAt:       a.CheckedAt,
+		CreatedAt:       a.CreatedAt,
+		UpdatedAt:       a.UpdatedAt,
+	}
 }
 
-func (s *Server) handleFeed(c *gin.Context) {
-	account, err := s.findAccount(c.Param("id"))
+func toArticleViews(articles []models.Article) []articleView {
+	result := make([]articleView, 0, len(articles))
+	for i := range articles {
+		result = append(result, toArticleView(&articles[i]))
+	}
+	return result
+}
+
+func (s *Server) handleGetArticle(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
 	if err != nil {
-		c.String(http.StatusNotFound, "account not found")
+		respondError(c, http.StatusNotFound, "article not found")
 		return
 	}
 
-	var articles []models.Article
-	if err := s.db.Where("account_id = ?", account.ID).
-		Order("published_at desc").
-		Limit(50).
-		Find(&articles).Error; err != nil {
-		c.String(http.StatusInternalServerError, "query error")
+	switch c.Query("format") {
+	case "":
+		views, err := s.articleViews(c, []models.Article{*article})
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to load article")
+			return
+		}
+		respondOK(c, apiData{"article": views[0]})
+	case "html":
+		// the body is third-party markup; a sandboxed origin keeps its
+		// scripts away from the console session
+		c.Header("Content-Security-Policy", "sandbox")
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(article.ContentHTML))
+	case "md":
+		markdown := article.ContentMarkdown
+		if markdown == "" {
+			markdown = content.Markdown(article.ContentHTML)
+		}
+		c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte("# "+article.Title+"\n\n"+markdown+"\n"))
+	case "txt":
+		text := article.ContentText
+		if text == "" {
+			text = content.PlainText(article.ContentHTML)
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(article.Title+"\n\n"+text+"\n"))
+	default:
+		respondError(c, http.StatusBadRequest, "format must be md, txt or html")
+	}
+}
+
+func (s *Server) handleListRevisions(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "article not found")
+		return
+	}
+
+	var revisions []models.ArticleRevision
+	if err := s.db.Where("article_id = ?", article.ID).
+		Order("revision asc").
+		Find(&revisions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load revisions")
 		return
 	}
 
-	items := make([]rssItem, 0, len(articles))
-	for _, article := range articles {
-		items = append(items, rssItem{
-			Title:       article.Title,
-			Link:        article.RawURL,
-			Description: article.Summary,
-			PubDate:     article.PublishedAt.Format(time.RFC1123Z),
-			GUID:        article.WechatArticleID,
-			Content:     article.ContentHTML,
-		})
+	result := make([]revisionView, 0, len(revisions))
+	for i := range revisions {
+		view := toRevisionView(&revisions[i])
+		view.ContentHTML = ""
+		result = append(result, view)
 	}
+	respondOK(c, apiData{"revisions": result})
+}
 
-	host := c.Request.Host
-	channel := rssChannel{
-		Title:         account.Name,
-		Link:          "https://" + host + "/feed/" + strconv.Itoa(int(account.ID)),
-		Description:   account.Alias,
-		LastBuildDate: time.Now().Format(time.RFC1123Z),
-		Items:         items,
+func (s *Server) handleGetRevision(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "article not found")
+		return
 	}
-	feed := rssFeed{
-		Version: "2.0",
-		Channel: channel,
+	revision, err := s.findRevision(article.ID, c.Param("rev"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "revision not found")
+		return
 	}
+	respondOK(c, apiData{"revision": toRevisionView(revision)})
+}
 
-	c.Header("Content-Type", "application/rss+xml; charset=utf-8")
-	enc := xml.NewEncoder(c.Writer)
-	enc.Indent("", "  ")
-	if err := enc.Encode(feed); err != nil {
-		c.String(http.StatusInternalServerError, "encode error")
+func (s *Server) handleDiffRevisions(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "article not found")
+		return
+	}
+	from, err := s.findRevision(article.ID, c.Query("from"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "from revision not found")
+		return
+	}
+	to, err := s.findRevision(article.ID, c.Query("to"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "to revision not found")
 		return
 	}
+
+	respondOK(c, apiData{
+		"from": from.Revision,
+		"to":   to.Revision,
+		"html": content.DiffHTML(from.ContentHTML, to.ContentHTML),
+	})
+}
+
+func (s *Server) findArticle(idParam string) (*models.Article, error) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, err
+	}
+	var article models.Article
+	if err := s.db.First(&article, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &article, nil
+}
+
+func (s *Server) findRevision(articleID uint, revParam string) (*models.ArticleRevision, error) {
+	rev, err := strconv.Atoi(revParam)
+	if err != nil {
+		return nil, err
+	}
+	var revision models.ArticleRevision
+	if err := s.db.First(&revision, "article_id = ? AND revision = ?", articleID, rev).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+type revisionView struct {
+	ID          uint      `json:"id"`
+	ArticleID   uint      `json:"article_id"`
+	Revision    int       `json:"revision"`
+	Title       string    `json:"title"`
+	Summary     string    `json:"summary"`
+	ContentHTML string    `json:"content_html,omitempty"`
+	BodyHash    string    `json:"body_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func toRevisionView(r *models.ArticleRevision) revisionView {
+	return revisionView{
+		ID:          r.ID,
+		ArticleID:   r.ArticleID,
+		Revision:    r.Revision,
+		Title:       r.Title,
+		Summary:     r.Summary,
+		ContentHTML: r.ContentHTML,
+		BodyHash:    r.BodyHash,
+		CreatedAt:   r.CreatedAt,
+	}
 }
@@ -0,0 +1,146 @@
+package http
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"wechat2rss/internal/models"
+)
+
+// Per-user article flags stored in user_article_states, each with the
+// column recording when it was last set.
+const (
+	stateRead     = "read"
+	stateStarred  = "starred"
+	stateArchived = "archived"
+)
+
+var stateTimeColumns = map[string]string{
+	stateRead:     "read_at",
+	stateStarred:  "starred_at",
+	stateArchived: "archived_at",
+}
+
+// stateBatchSize bounds the rows written by one upsert.
+const stateBatchSize = 500
+
+// withState restricts an article query to articles whose flag is set for
+// userID, or to those where it is not when set is false.
+func withState(query *gorm.DB, userID uint, flag string, set bool) *gorm.DB {
+	cond := fmt.Sprintf("EXISTS (SELECT 1 FROM user_article_states uas WHERE uas.article_id = articles.id AND uas.user_id = ? AND uas.%s)", flag)
+	if !set {
+		cond = "NOT " + cond
+	}
+	return query.Where(cond, userID)
+}
+
+// setArticleState sets flag to value on the given articles for userID,
+// creating state rows as needed.
+func (s *Server) setArticleState(userID uint, articleIDs []uint, flag string, value bool) error {
+	timeColumn, ok := stateTimeColumns[flag]
+	if !ok {
+		return fmt.Errorf("unknown state %s", flag)
+	}
+	now := time.Now()
+	var at *time.Time
+	if value {
+		at = &now
+	}
+
+	for start := 0; start < len(articleIDs); start += stateBatchSize {
+		batch := articleIDs[start:min(start+stateBatchSize, len(articleIDs))]
+		rows := make([]models.UserArticleState, 0, len(batch))
+		for _, id := range batch {
+			row := models.UserArticleState{UserID: userID, ArticleID: id}
+			switch flag {
+			case stateRead:
+				row.Read, row.ReadAt = value, at
+			case stateStarred:
+				row.Starred, row.StarredAt = value, at
+			case stateArchived:
+				row.Archived, row.ArchivedAt = value, at
+			}
+			rows = append(rows, row)
+		}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "user_id"}, {Name: "article_id"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				flag:         value,
+				timeColumn:   at,
+				"updated_at": now,
+			}),
+		}).Create(&rows).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// articleStates loads userID's state rows for articles keyed by article.
+func (s *Server) articleStates(userID uint, articles []models.Article) (map[uint]models.UserArticleState, error) {
+	ids := make([]uint, 0, len(articles))
+	for _, article := range articles {
+		ids = append(ids, article.ID)
+	}
+	states := make(map[uint]models.UserArticleState)
+	if len(ids) == 0 {
+		return states, nil
+	}
+	var rows []models.UserArticleState
+	if err := s.db.Where("user_id = ? AND article_id IN ?", userID, ids).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		states[row.ArticleID] = row
+	}
+	return states, nil
+}
+
+// unreadCount is the number of unread articles of an account and the
+// publish time of the newest of them.
+type unreadCount struct {
+	AccountID uint
+	Count     int
+	Newest    time.Time
+}
+
+// unreadCounts returns the unread articles per account for userID.
+func (s *Server) unreadCounts(userID uint) (map[uint]unreadCount, error) {
+	var rows []unreadCount
+	query := withState(s.db.Model(&models.Article{}), userID, stateRead, false)
+	if err := query.Select("articles.account_id, count(*) AS count, max(articles.published_at) AS newest").
+		Group("articles.account_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[uint]unreadCount, len(rows))
+	for _, row := range rows {
+		counts[row.AccountID] = row
+	}
+	return counts, nil
+}
+
+// setQueryState sets flag to value for userID on every article matched by
+// query in a single INSERT ... SELECT, so large streams are not loaded
+// into memory. It returns the number of state rows written. The casts
+// let PostgreSQL type the parameters, which the SELECT list cannot infer.
+func (s *Server) setQueryState(userID uint, query *gorm.DB, flag string, value bool) (int64, error) {
+	timeColumn, ok := stateTimeColumns[flag]
+	if !ok {
+		return 0, fmt.Errorf("unknown state %s", flag)
+	}
+	now := time.Now()
+	var at *time.Time
+	if value {
+		at = &now
+	}
+	sql := fmt.Sprintf(`INSERT INTO user_article_states (user_id, article_id, %[1]s, %[2]s, created_at, updated_at)
+SELECT CAST(? AS bigint), matched.id, CAST(? AS boolean), CAST(? AS timestamptz), CAST(? AS timestamptz), CAST(? AS timestamptz) FROM (?) AS matched
+ON CONFLICT (user_id, article_id) DO UPDATE SET %[1]s = excluded.%[1]s, %[2]s = excluded.%[2]s, updated_at = excluded.updated_at`,
+		flag, timeColumn)
+	result := s.db.Exec(sql, userID, value, at, now, now, query.Select("articles.id"))
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,265 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"wechat2rss/internal/models"
+)
+
+// maxTagLength bounds a single user tag.
+const maxTagLength = 64
+
+type articleStateView struct {
+	Read       bool       `json:"read"`
+	Starred    bool       `json:"starred"`
+	Archived   bool       `json:"archived"`
+	ReadAt     *time.Time `json:"read_at"`
+	StarredAt  *time.Time `json:"starred_at"`
+	ArchivedAt *time.Time `json:"archived_at"`
+	Tags       []string   `json:"tags"`
+	Note       string     `json:"note"`
+}
+
+func toArticleStateView(state models.UserArticleState, tags []string) *articleStateView {
+	if tags == nil {
+		tags = []string{}
+	}
+	return &articleStateView{
+		Read:       state.Read,
+		Starred:    state.Starred,
+		Archived:   state.Archived,
+		ReadAt:     state.ReadAt,
+		StarredAt:  state.StarredAt,
+		ArchivedAt: state.ArchivedAt,
+		Tags:       tags,
+		Note:       state.Note,
+	}
+}
+
+// sessionUserID returns the id of the authenticated user.
+func sessionUserID(c *gin.Context) uint {
+	id, _ := c.Get("user_id")
+	userID, _ := id.(uint)
+	return userID
+}
+
+// articleViews converts articles and attaches the current user's state.
+func (s *Server) articleViews(c *gin.Context, articles []models.Article) ([]articleView, error) {
+	userID := sessionUserID(c)
+	states, err := s.articleStates(userID, articles)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := s.articleTags(userID, articles)
+	if err != nil {
+		return nil, err
+	}
+	views := toArticleViews(articles)
+	for i := range views {
+		views[i].State = toArticleStateView(states[views[i].ID], tags[views[i].ID])
+	}
+	return views, nil
+}
+
+// articleTags loads userID's tags for articles keyed by article.
+func (s *Server) articleTags(userID uint, articles []models.Article) (map[uint][]string, error) {
+	ids := make([]uint, 0, len(articles))
+	for _, article := range articles {
+		ids = append(ids, article.ID)
+	}
+	tags := make(map[uint][]string)
+	if len(ids) == 0 {
+		return tags, nil
+	}
+	var rows []models.UserArticleTag
+	if err := s.db.Where("user_id = ? AND article_id IN ?", userID, ids).Order("tag").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		tags[row.ArticleID] = append(tags[row.ArticleID], row.Tag)
+	}
+	return tags, nil
+}
+
+func (s *Server) handleGetArticleState(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "article not found")
+		return
+	}
+	views, err := s.articleViews(c, []models.Article{*article})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load state")
+		return
+	}
+	respondOK(c, apiData{"state": views[0].State})
+}
+
+type articleStateRequest struct {
+	Read     *bool     `json:"read"`
+	Starred  *bool     `json:"starred"`
+	Archived *bool     `json:"archived"`
+	Tags     *[]string `json:"tags"`
+	Note     *string   `json:"note"`
+}
+
+// handleUpdateArticleState changes the fields present in the request and
+// leaves the others alone. tags replaces the whole tag set.
+func (s *Server) handleUpdateArticleState(c *gin.Context) {
+	article, err := s.findArticle(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "article not found")
+		return
+	}
+	var req articleStateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	var tags []string
+	if req.Tags != nil {
+		if tags, err = normalizeTags(*req.Tags); err != nil {
+			respondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	userID := sessionUserID(c)
+	ids := []uint{article.ID}
+	for flag, value := range map[string]*bool{stateRead: req.Read, stateStarred: req.Starred, stateArchived: req.Archived} {
+		if value == nil {
+			continue
+		}
+		if err := s.setArticleState(userID, ids, flag, *value); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update state")
+			return
+		}
+	}
+	if req.Note != nil {
+		row := models.UserArticleState{UserID: userID, ArticleID: article.ID, Note: *req.Note}
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "article_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"note", "updated_at"}),
+		}).Create(&row).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update note")
+			return
+		}
+	}
+	if req.Tags != nil {
+		if err := s.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ? AND article_id = ?", userID, article.ID).
+				Delete(&models.UserArticleTag{}).Error; err != nil {
+				return err
+			}
+			rows := make([]models.UserArticleTag, 0, len(tags))
+			for _, tag := range tags {
+				rows = append(rows, models.UserArticleTag{UserID: userID, ArticleID: article.ID, Tag: tag})
+			}
+			if len(rows) == 0 {
+				return nil
+			}
+			return tx.Create(&rows).Error
+		}); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update tags")
+			return
+		}
+	}
+
+	s.handleGetArticleState(c)
+}
+
+// normalizeTags trims and de-duplicates tags.
+func normalizeTags(raw []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, tag := range raw {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || seen[tag] {
+			continue
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, errors.New("tag too long")
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+type markReadRequest struct {
+	AccountID  *uint  `json:"account_id"`
+	Group      string `json:"group"`
+	Before     string `json:"before"`
+	ArticleIDs []uint `json:"article_ids"`
+	Unread     bool   `json:"unread"`
+}
+
+// handleMarkRead marks every article matching the request read, or unread
+// when unread is set. At least one scope is required so an empty body
+// cannot mark the whole archive.
+func (s *Server) handleMarkRead(c *gin.Context) {
+	var req markReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.AccountID == nil && req.Group == "" && req.Before == "" && len(req.ArticleIDs) == 0 {
+		respondError(c, http.StatusBadRequest, "account_id, group, before or article_ids required")
+		return
+	}
+
+	userID := sessionUserID(c)
+	query := s.db.Model(&models.Article{})
+	if req.AccountID != nil {
+		query = query.Where("articles.account_id = ?", *req.AccountID)
+	}
+	if req.Group != "" {
+		query = s.groupScope(req.Group)(query)
+	}
+	if req.Before != "" {
+		t, err := parseDate(req.Before)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before")
+			return
+		}
+		query = query.Where("articles.published_at < ?", t)
+	}
+	if len(req.ArticleIDs) > 0 {
+		query = query.Where("articles.id IN ?", req.ArticleIDs)
+	}
+	// only touch rows whose state actually changes
+	query = withState(query, userID, stateRead, req.Unread)
+
+	updated, err := s.setQueryState(userID, query, stateRead, !req.Unread)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update state")
+		return
+	}
+	respondOK(c, apiData{"updated": updated})
+}
+
+type tagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+func (s *Server) handleListTags(c *gin.Context) {
+	var tags []tagCount
+	if err := s.db.Model(&models.UserArticleTag{}).
+		Select("tag, count(*) AS count").
+		Where("user_id = ?", sessionUserID(c)).
+		Group("tag").
+		Order("tag").
+		Scan(&tags).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list tags")
+		return
+	}
+	respondOK(c, apiData{"tags": tags})
+}
@@ -0,0 +1,259 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+)
+
+const (
+	auditTargetKey = "audit_target"
+	auditBeforeKey = "audit_before"
+	auditAfterKey  = "audit_after"
+)
+
+// auditChange hands the audit log the state of the request's target before
+// and after the change; either may be nil for creations and deletions.
+// Values are stored as JSON, so pass views without secrets.
+func auditChange(c *gin.Context, targetID any, before, after any) {
+	c.Set(auditTargetKey, fmt.Sprint(targetID))
+	if before != nil {
+		c.Set(auditBeforeKey, before)
+	}
+	if after != nil {
+		c.Set(auditAfterKey, after)
+	}
+}
+
+// auditRequests records every state-changing /api and reader API request
+// once its handler has run. Requests that never got past authentication are left
+// out; rejected logins are kept in login_attempts instead.
+func (s *Server) auditRequests(c *gin.Context) {
+	c.Next()
+
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	if c.FullPath() == "" {
+		return
+	}
+	actorID, ok := c.Get("user_id")
+	if !ok {
+		return
+	}
+
+	event := models.AuditEvent{
+		Action:     c.Request.Method + " " + c.FullPath(),
+		TargetType: auditTargetType(c.FullPath()),
+		TargetID:   c.Param("id"),
+		Status:     c.Writer.Status(),
+		IP:         c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+	}
+	if id, ok := actorID.(uint); ok {
+		event.ActorID = &id
+	}
+	if user, ok := c.Get("user"); ok {
+		event.ActorName = user.(*models.User).Username
+	}
+	if token, ok := c.Get("api_token"); ok {
+		event.TokenID = &token.(*models.APIToken).ID
+	}
+	if target := c.GetString(auditTargetKey); target != "" {
+		event.TargetID = target
+	}
+	if before, ok := c.Get(auditBeforeKey); ok {
+		event.Before = auditJSON(before)
+	}
+	if after, ok := c.Get(auditAfterKey); ok {
+		event.After = auditJSON(after)
+	}
+	if err := s.db.Create(&event).Error; err != nil {
+		log.Printf("audit %s error: %v", event.Action, err)
+	}
+}
+
+// auditTargetType names the resource of route: the path below /api up to
+// the first parameter, e.g. "accounts" or "wechat/sessions". Reader API
+// routes are prefixed with "reader/", e.g. "reader/edit-tag".
+func auditTargetType(route string) string {
+	if rest, ok := strings.CutPrefix(route, readerAPIPath+"/"); ok {
+		route = "/api/reader/" + rest
+	} else if rest, ok := strings.CutPrefix(route, "/greader/"); ok {
+		route = "/api/reader/" + rest
+	}
+	var parts []string
+	for _, part := range strings.Split(strings.TrimPrefix(route, "/api/"), "/") {
+		if strings.HasPrefix(part, ":") || strings.HasPrefix(part, "*") {
+			break
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, "/")
+}
+
+func auditJSON(v any) *string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("audit encode error: %v", err)
+		return nil
+	}
+	s := string(b)
+	return &s
+}
+
+type auditEventView struct {
+	ID         uint                   `json:"id"`
+	ActorID    *uint                  `json:"actor_id"`
+	Actor      string                 `json:"actor"`
+	TokenID    *uint                  `json:"token_id,omitempty"`
+	Action     string                 `json:"action"`
+	TargetType string                 `json:"target_type"`
+	TargetID   string                 `json:"target_id"`
+	Status     int                    `json:"status"`
+	Before     json.RawMessage        `json:"before,omitempty"`
+	After      json.RawMessage        `json:"after,omitempty"`
+	Changes    map[string]fieldChange `json:"changes,omitempty"`
+	IP         string                 `json:"ip"`
+	UserAgent  string                 `json:"user_agent"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type fieldChange struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+func toAuditEventView(e *models.AuditEvent) auditEventView {
+	view := auditEventView{
+		ID:         e.ID,
+		ActorID:    e.ActorID,
+		Actor:      e.ActorName,
+		TokenID:    e.TokenID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Status:     e.Status,
+		IP:         e.IP,
+		UserAgent:  e.UserAgent,
+		CreatedAt:  e.CreatedAt,
+	}
+	if e.Before != nil {
+		view.Before = json.RawMessage(*e.Before)
+	}
+	if e.After != nil {
+		view.After = json.RawMessage(*e.After)
+	}
+	if e.Before != nil && e.After != nil {
+		view.Changes = diffJSON(view.Before, view.After)
+	}
+	return view
+}
+
+// diffJSON lists the top-level fields that differ between two JSON objects.
+func diffJSON(before, after json.RawMessage) map[string]fieldChange {
+	var from, to map[string]any
+	if json.Unmarshal(before, &from) != nil || json.Unmarshal(after, &to) != nil {
+		return nil
+	}
+	changes := map[string]fieldChange{}
+	for key, value := range from {
+		if !reflect.DeepEqual(value, to[key]) {
+			changes[key] = fieldChange{From: value, To: to[key]}
+		}
+	}
+	for key, value := range to {
+		if _, ok := from[key]; !ok {
+			changes[key] = fieldChange{To: value}
+		}
+	}
+	return changes
+}
+
+// handleListAudit lists audit events newest first. Filters: actor_id,
+// actor, action, target_type, target_id, from, to and failed; pages are
+// continued with before=<next_before>.
+func (s *Server) handleListAudit(c *gin.Context) {
+	query := s.db.Model(&models.AuditEvent{})
+	if v := c.Query("actor_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid actor_id")
+			return
+		}
+		query = query.Where("actor_id = ?", id)
+	}
+	if v := c.Query("actor"); v != "" {
+		query = query.Where("actor_name = ?", v)
+	}
+	if v := c.Query("action"); v != "" {
+		query = query.Where("action = ?", v)
+	}
+	if v := c.Query("target_type"); v != "" {
+		query = query.Where("target_type = ?", v)
+	}
+	if v := c.Query("target_id"); v != "" {
+		query = query.Where("target_id = ?", v)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid from")
+			return
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := parseDate(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid to")
+			return
+		}
+		if len(v) == len("2006-01-02") {
+			// a bare date includes the whole day
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", t)
+	}
+	if v := c.Query("failed"); v != "" {
+		if failed, _ := strconv.ParseBool(v); failed {
+			query = query.Where("status >= 400")
+		} else {
+			query = query.Where("status < 400")
+		}
+	}
+	if v := c.Query("before"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "invalid before")
+			return
+		}
+		query = query.Where("id < ?", id)
+	}
+
+	limit := pageSize(c.Query("limit"))
+	var events []models.AuditEvent
+	if err := query.Order("id DESC").Limit(limit).Find(&events).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list audit events")
+		return
+	}
+	result := make([]auditEventView, 0, len(events))
+	for i := range events {
+		result = append(result, toAuditEventView(&events[i]))
+	}
+	data := apiData{"events": result}
+	if len(events) == limit {
+		data["next_before"] = events[len(events)-1].ID
+	}
+	respondOK(c, data)
+}
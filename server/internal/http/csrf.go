@@ -0,0 +1,105 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/util"
+)
+
+const (
+	csrfHeader     = "X-CSRF-Token"
+	csrfSessionKey = "csrf_token"
+)
+
+// corsMiddleware lets any origin read the public feeds, and only origins
+// in allowed call the API, with cookies. Without allowed origins the API
+// is same-origin only. "*" opens the API to every origin but without
+// credentials, so only Bearer tokens work cross-origin.
+func corsMiddleware(allowed []string) gin.HandlerFunc {
+	feeds := cors.New(cors.Config{
+		AllowAllOrigins: true,
+		AllowMethods:    []string{http.MethodGet, http.MethodHead},
+		MaxAge:          12 * time.Hour,
+	})
+
+	var api gin.HandlerFunc
+	if len(allowed) > 0 {
+		config := cors.Config{
+			AllowMethods:  []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete},
+			AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", csrfHeader},
+			ExposeHeaders: []string{"Retry-After"},
+			MaxAge:        12 * time.Hour,
+		}
+		if slices.Contains(allowed, "*") {
+			config.AllowAllOrigins = true
+		} else {
+			config.AllowOrigins = allowed
+			config.AllowCredentials = true
+		}
+		api = cors.New(config)
+	}
+
+	return func(c *gin.Context) {
+		switch {
+		case strings.HasPrefix(c.Request.URL.Path, "/feed/"):
+			feeds(c)
+		case api != nil:
+			api(c)
+		default:
+			c.Next()
+		}
+	}
+}
+
+// requireCSRF rejects state-changing requests that rely on the session
+// cookie unless they echo the session's CSRF token in the X-CSRF-Token
+// header, which other sites cannot read or set. Requests authenticated by
+// Bearer token carry no ambient credentials and are exempt.
+func (s *Server) requireCSRF(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		c.Next()
+		return
+	}
+	if strings.HasPrefix(c.GetHeader("Authorization"), "Bearer ") {
+		c.Next()
+		return
+	}
+	expected, _ := sessions.Default(c).Get(csrfSessionKey).(string)
+	given := c.GetHeader(csrfHeader)
+	if expected == "" || subtle.ConstantTimeCompare([]byte(expected), []byte(given)) != 1 {
+		respondError(c, http.StatusForbidden, "invalid csrf token")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+// handleCSRFToken returns the CSRF token of the session, starting a
+// session when there is none so the login form is covered too.
+func (s *Server) handleCSRFToken(c *gin.Context) {
+	session := sessions.Default(c)
+	token, _ := session.Get(csrfSessionKey).(string)
+	if token == "" {
+		var err error
+		if token, err = util.RandHex(32); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to generate token")
+			return
+		}
+		session.Set(csrfSessionKey, token)
+		if err := session.Save(); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to persist session")
+			return
+		}
+	}
+	c.Header("Cache-Control", "no-store")
+	respondOK(c, apiData{"token": token})
+}
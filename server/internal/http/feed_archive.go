@@ -0,0 +1,164 @@
+package http
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/feed"
+	"wechat2rss/internal/models"
+)
+
+// archiveZone is the time zone monthly archives are cut in; WeChat
+// publishing happens on China Standard Time.
+var archiveZone = time.FixedZone("CST", 8*60*60)
+
+const archiveMonthLayout = "2006-01"
+
+// feedLocation knows the URLs of an account feed, its pages and archives.
+type feedLocation struct {
+	base   string // absolute URL of the feed without extension or query
+	format feed.Format
+	token  string // repeated as ?token= when the request carried one
+}
+
+func (l feedLocation) url(path string, query url.Values) string {
+	if l.token != "" {
+		query.Set("token", l.token)
+	}
+	u := l.base + path + feedExt(l.format)
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	return u
+}
+
+// page returns the URL of page n; page 1 is the subscription document.
+func (l feedLocation) page(n int) string {
+	query := url.Values{}
+	if n > 1 {
+		query.Set("page", strconv.Itoa(n))
+	}
+	return l.url("", query)
+}
+
+func (l feedLocation) archive(month string) string {
+	return l.url("/archive/"+month, url.Values{})
+}
+
+func archiveMonth(t time.Time) string {
+	return t.In(archiveZone).Format(archiveMonthLayout)
+}
+
+// currentMonthStart returns the start of the month still being written;
+// only the months before it are archived.
+func currentMonthStart() time.Time {
+	now := time.Now().In(archiveZone)
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, archiveZone)
+}
+
+func (s *Server) handleFeedArchive(c *gin.Context) {
+	month, format, ok := splitFeedParam(c.Param("month"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	account, loc, ok := s.accountFeedLocation(c, c.Param("id"), format)
+	if !ok {
+		return
+	}
+	s.serveArchive(c, account, loc, month)
+}
+
+func (s *Server) handleTokenFeedArchive(c *gin.Context) {
+	month, format, ok := splitFeedParam(c.Param("month"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	account, loc, ok := s.tokenFeedLocation(c, c.Param("token"), format)
+	if !ok {
+		return
+	}
+	s.serveArchive(c, account, loc, month)
+}
+
+// serveArchive serves the RFC 5005 archive document holding every
+// article an account published in month, linked to the neighbouring
+// months that have articles and to the subscription document. Archive
+// documents must not change, so the current month is not served until
+// it has ended.
+func (s *Server) serveArchive(c *gin.Context, account *models.Account, loc feedLocation, month string) {
+	current := currentMonthStart()
+	start, err := time.ParseInLocation(archiveMonthLayout, month, archiveZone)
+	if err != nil || !start.Before(current) {
+		c.String(http.StatusNotFound, "archive not found")
+		return
+	}
+	end := start.AddDate(0, 1, 0)
+
+	opts, err := s.feedOptions(c.Request.URL.Query(), account.FeedOptions)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	opts.Limit = -1 // archives are complete
+
+	selfURL := loc.archive(month)
+	key := fmt.Sprintf("archive:%d|%s|%s|%s", account.ID, loc.format, selfURL, feedOptionsKey(opts))
+	if !feedCacheable(c.Request.URL.Query()) {
+		key = ""
+	}
+	s.serveFeed(c, key, &account.ID, loc.format, func() (*feed.Feed, error) {
+		var articles []models.Article
+		query := s.db.Where("articles.account_id = ? AND articles.published_at >= ? AND articles.published_at < ?",
+			account.ID, start, end).Order("articles.published_at desc, articles.id desc")
+		if err := applyFeedOptions(query, opts).Find(&articles).Error; err != nil {
+			return nil, err
+		}
+		prev, err := s.neighbourMonth(account.ID, "max", "published_at < ?", start)
+		if err != nil {
+			return nil, err
+		}
+		next, err := s.neighbourMonth(account.ID, "min", "published_at >= ? AND published_at < ?", end, current)
+		if err != nil {
+			return nil, err
+		}
+
+		f := feed.FromAccount(account, articles, selfURL)
+		if opts.SummaryOnly {
+			f.StripContent()
+		}
+		f.Archive = true
+		f.AddLink(feed.RelCurrent, loc.page(1))
+		if prev != "" {
+			f.AddLink(feed.RelPrevArchive, loc.archive(prev))
+		}
+		if next != "" {
+			f.AddLink(feed.RelNextArchive, loc.archive(next))
+		}
+		return f, nil
+	})
+}
+
+// neighbourMonth returns the archive month of the article picked by agg
+// (min or max publish time) among those matching cond, or "" if none.
+func (s *Server) neighbourMonth(accountID uint, agg, cond string, args ...any) (string, error) {
+	var published sql.NullTime
+	if err := s.db.Model(&models.Article{}).
+		Select(agg+"(published_at)").
+		Where("account_id = ?", accountID).
+		Where(cond, args...).
+		Scan(&published).Error; err != nil {
+		return "", err
+	}
+	if !published.Valid {
+		return "", nil
+	}
+	return archiveMonth(published.Time), nil
+}
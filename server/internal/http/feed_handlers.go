@@ -0,0 +1,409 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/feed"
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/websub"
+)
+
+var errFeedForbidden = errors.New("feed token required")
+
+func (s *Server) handleFeed(c *gin.Context) {
+	idParam, format, ok := splitFeedParam(c.Param("id"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	if idParam == "all" {
+		s.serveAggregateFeed(c, format, "all", "全部公众号", "所有公众号的最新文章", "/feed/all", nil)
+		return
+	}
+	account, loc, ok := s.accountFeedLocation(c, idParam, format)
+	if !ok {
+		return
+	}
+	s.serveAccountFeed(c, account, loc)
+}
+
+// accountFeedLocation authorizes a request for the feed of account
+// idParam and returns where that feed lives, writing the error response
+// itself when the request is refused.
+func (s *Server) accountFeedLocation(c *gin.Context, idParam string, format feed.Format) (*models.Account, feedLocation, bool) {
+	account, err := s.findAccount(idParam)
+	if err != nil {
+		c.String(http.StatusNotFound, "account not found")
+		return nil, feedLocation{}, false
+	}
+
+	token, err := s.authorizeFeed(c, &account.ID, account.FeedPrivate)
+	if err != nil {
+		s.rejectFeed(c, err)
+		return nil, feedLocation{}, false
+	}
+
+	loc := feedLocation{base: s.baseURL(c) + "/feed/" + strconv.Itoa(int(account.ID)), format: format}
+	if token != nil && c.Query("token") != "" {
+		loc.token = token.Token
+	}
+	return account, loc, true
+}
+
+func (s *Server) handleTokenFeed(c *gin.Context) {
+	tokenParam, format, ok := splitFeedParam(c.Param("token"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	account, loc, ok := s.tokenFeedLocation(c, tokenParam, format)
+	if !ok {
+		return
+	}
+	s.serveAccountFeed(c, account, loc)
+}
+
+func (s *Server) tokenFeedLocation(c *gin.Context, tokenParam string, format feed.Format) (*models.Account, feedLocation, bool) {
+	var token models.FeedToken
+	if err := s.db.First(&token, "token = ?", tokenParam).Error; err != nil || token.AccountID == nil {
+		c.String(http.StatusNotFound, "feed not found")
+		return nil, feedLocation{}, false
+	}
+	var account models.Account
+	if err := s.db.First(&account, "id = ?", *token.AccountID).Error; err != nil {
+		c.String(http.StatusNotFound, "account not found")
+		return nil, feedLocation{}, false
+	}
+	s.recordFeedAccess(c, &token)
+	return &account, feedLocation{base: s.baseURL(c) + "/feed/t/" + token.Token, format: format}, true
+}
+
+// serveAccountFeed serves the subscription document of an account feed,
+// or an older page of it when ?page= is given. The first page links to
+// the newest monthly archive so readers can walk the full history.
+func (s *Server) serveAccountFeed(c *gin.Context, account *models.Account, loc feedLocation) {
+	opts, err := s.feedOptions(c.Request.URL.Query(), account.FeedOptions)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	page := 1
+	if v := c.Query("page"); v != "" {
+		if page, err = strconv.Atoi(v); err != nil || page < 1 {
+			c.String(http.StatusBadRequest, "invalid page")
+			return
+		}
+	}
+
+	selfURL := loc.page(page)
+	key := fmt.Sprintf("account:%d|%s|%s|%s", account.ID, loc.format, selfURL, feedOptionsKey(opts))
+	if !feedCacheable(c.Request.URL.Query()) {
+		key = ""
+	}
+	s.serveFeed(c, key, &account.ID, loc.format, func() (*feed.Feed, error) {
+		f, more, err := s.buildAccountFeed(account, opts, selfURL, page)
+		if err != nil {
+			return nil, err
+		}
+		f.AddLink(feed.RelFirst, loc.page(1))
+		if page > 1 {
+			f.AddLink(feed.RelPrevious, loc.page(page-1))
+		}
+		if more {
+			f.AddLink(feed.RelNext, loc.page(page+1))
+		}
+		if page == 1 {
+			// the newest completed month; the current one is not archived yet
+			prev, err := s.neighbourMonth(account.ID, "max", "published_at < ?", currentMonthStart())
+			if err != nil {
+				return nil, err
+			}
+			if prev != "" {
+				f.AddLink(feed.RelPrevArchive, loc.archive(prev))
+			}
+		}
+		return f, nil
+	})
+}
+
+// buildAccountFeed builds one page of an account feed and reports whether
+// older articles follow it.
+func (s *Server) buildAccountFeed(account *models.Account, opts models.FeedOptions, selfURL string, page int) (*feed.Feed, bool, error) {
+	var articles []models.Article
+	query := s.db.Where("articles.account_id = ?", account.ID).Order("articles.published_at desc, articles.id desc")
+	query = applyFeedOptions(query, opts).Offset((page - 1) * opts.Limit).Limit(opts.Limit + 1)
+	if err := query.Find(&articles).Error; err != nil {
+		return nil, false, err
+	}
+	more := len(articles) > opts.Limit
+	if more {
+		articles = articles[:opts.Limit]
+	}
+	f := feed.FromAccount(account, articles, selfURL)
+	if opts.SummaryOnly {
+		f.StripContent()
+	}
+	return f, more, nil
+}
+
+func (s *Server) handleGroupFeed(c *gin.Context) {
+	name, format, ok := splitFeedParam(c.Param("name"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	s.serveAggregateFeed(c, format, "group:"+name, name, "分组「"+name+"」的最新文章", "/feed/group/"+url.PathEscape(name),
+		s.groupScope(name))
+}
+
+func (s *Server) handleSavedSearchFeed(c *gin.Context) {
+	idParam, format, ok := splitFeedParam(c.Param("id"), c.GetHeader("Accept"))
+	if !ok {
+		c.String(http.StatusNotFound, "unknown feed format")
+		return
+	}
+	saved, err := s.findSavedSearch(idParam)
+	if err != nil {
+		c.String(http.StatusNotFound, "saved search not found")
+		return
+	}
+	s.serveAggregateFeed(c, format, "search:"+strconv.Itoa(int(saved.ID)), saved.Name, "关键词："+saved.Query,
+		"/feed/search/"+strconv.Itoa(int(saved.ID)), func(query *gorm.DB) *gorm.DB {
+			query = s.search.Match(query, saved.Query)
+			if saved.GroupName != "" {
+				query = s.groupScope(saved.GroupName)(query)
+			}
+			return query
+		})
+}
+
+// groupScope restricts an article query to the accounts of group name.
+func (s *Server) groupScope(name string) func(*gorm.DB) *gorm.DB {
+	return func(query *gorm.DB) *gorm.DB {
+		return query.Where("articles.account_id IN (?)",
+			s.db.Model(&models.Account{}).Select("id").Where("group_name = ?", name))
+	}
+}
+
+// serveAggregateFeed renders a feed merging articles across accounts.
+// Articles of private accounts are only included for global tokens.
+func (s *Server) serveAggregateFeed(c *gin.Context, format feed.Format, id, title, description, feedPath string, scope func(*gorm.DB) *gorm.DB) {
+	token, err := s.authorizeFeed(c, nil, false)
+	if err != nil {
+		s.rejectFeed(c, err)
+		return
+	}
+
+	selfURL := s.baseURL(c) + feedPath + feedExt(format)
+	if token != nil && c.Query("token") != "" {
+		selfURL += "?token=" + url.QueryEscape(token.Token)
+	}
+	opts, err := s.feedOptions(c.Request.URL.Query(), models.FeedOptions{})
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	key := fmt.Sprintf("%s|%s|%s|%t|%s", id, format, selfURL, token != nil, feedOptionsKey(opts))
+	if !feedCacheable(c.Request.URL.Query()) {
+		key = ""
+	}
+	s.serveFeed(c, key, nil, format, func() (*feed.Feed, error) {
+		return s.buildAggregateFeed(id, title, description, selfURL, scope, token != nil, opts)
+	})
+}
+
+func (s *Server) buildAggregateFeed(id, title, description, selfURL string, scope func(*gorm.DB) *gorm.DB, includePrivate bool, opts models.FeedOptions) (*feed.Feed, error) {
+	query := s.db.Model(&models.Article{})
+	if scope != nil {
+		query = scope(query)
+	}
+	if !includePrivate {
+		query = query.Where("articles.account_id NOT IN (?)",
+			s.db.Model(&models.Account{}).Select("id").Where("feed_private = ?", true))
+	}
+	var articles []models.Article
+	query = applyFeedOptions(query.Order("articles.published_at desc"), opts)
+	if err := query.Find(&articles).Error; err != nil {
+		return nil, err
+	}
+	accounts, err := s.accountsOf(articles)
+	if err != nil {
+		return nil, err
+	}
+	f := feed.Aggregate(id, title, description, articles, accounts, selfURL)
+	if opts.SummaryOnly {
+		f.StripContent()
+	}
+	return f, nil
+}
+
+// accountsOf loads the accounts referenced by articles keyed by ID.
+func (s *Server) accountsOf(articles []models.Article) (map[uint]*models.Account, error) {
+	ids := make([]uint, 0, len(articles))
+	for _, article := range articles {
+		ids = append(ids, article.AccountID)
+	}
+	accounts := make(map[uint]*models.Account)
+	if len(ids) == 0 {
+		return accounts, nil
+	}
+	var list []models.Account
+	if err := s.db.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for i := range list {
+		accounts[list[i].ID] = &list[i]
+	}
+	return accounts, nil
+}
+
+// serveFeed writes the feed cached under key, building and caching it
+// first when needed, and answers conditional requests with 304. An empty
+// key bypasses the cache.
+func (s *Server) serveFeed(c *gin.Context, key string, accountID *uint, format feed.Format, build func() (*feed.Feed, error)) {
+	hub := s.hubURL(s.baseURL(c))
+	var rendered *feed.Rendered
+	ok := false
+	if key != "" {
+		rendered, ok = s.feeds.Get(key)
+	}
+	if !ok {
+		f, err := build()
+		if err != nil {
+			c.String(http.StatusInternalServerError, "query error")
+			return
+		}
+		f.Hub = hub
+		rendered, err = feed.RenderDocument(f, format)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "encode error")
+			return
+		}
+		if key != "" {
+			s.feeds.Put(key, accountID, rendered)
+		}
+	}
+
+	if hub != "" {
+		c.Header("Link", websub.LinkHeader(hub, rendered.SelfURL))
+	}
+	c.Header("Vary", "Accept")
+	c.Header("ETag", rendered.ETag)
+	c.Header("Last-Modified", rendered.LastModified.Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+	if notModified(c.Request, rendered) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, rendered.ContentType, rendered.Body)
+}
+
+// notModified evaluates If-None-Match, falling back to If-Modified-Since
+// only when no entity tag was sent, as RFC 9110 requires.
+func notModified(r *http.Request, rendered *feed.Rendered) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == "*" || tag == rendered.ETag {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !rendered.LastModified.After(t)
+	}
+	return false
+}
+
+// authorizeFeed checks the token presented as ?token= or as the HTTP Basic
+// password. Public feeds accept requests without one; a presented token
+// must be valid either way. accountID nil means an aggregated feed, which
+// only global tokens open.
+func (s *Server) authorizeFeed(c *gin.Context, accountID *uint, private bool) (*models.FeedToken, error) {
+	value := c.Query("token")
+	if value == "" {
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			value = password
+		}
+	}
+	if value == "" {
+		if private {
+			return nil, errFeedForbidden
+		}
+		return nil, nil
+	}
+
+	var token models.FeedToken
+	if err := s.db.First(&token, "token = ?", value).Error; err != nil {
+		return nil, errFeedForbidden
+	}
+	if token.AccountID != nil && (accountID == nil || *token.AccountID != *accountID) {
+		return nil, errFeedForbidden
+	}
+	s.recordFeedAccess(c, &token)
+	return &token, nil
+}
+
+func (s *Server) rejectFeed(c *gin.Context, err error) {
+	c.Header("WWW-Authenticate", `Basic realm="Wechat2RSS feeds", charset="UTF-8"`)
+	c.String(http.StatusUnauthorized, err.Error())
+}
+
+func (s *Server) recordFeedAccess(c *gin.Context, token *models.FeedToken) {
+	now := time.Now()
+	if err := s.db.Model(token).Updates(map[string]any{
+		"last_fetched_at": now,
+		"last_user_agent": c.Request.UserAgent(),
+		"last_ip":         c.ClientIP(),
+	}).Error; err != nil {
+		log.Printf("feed token %d access log error: %v", token.ID, err)
+	}
+}
+
+// splitFeedParam separates an optional format extension from a feed path
+// parameter such as "12.atom". Without an extension the format is
+// negotiated from the Accept header.
+func splitFeedParam(param, accept string) (string, feed.Format, bool) {
+	ext := path.Ext(param)
+	if ext == "" {
+		return param, feed.Negotiate(accept), true
+	}
+	format, ok := feed.ParseFormat(strings.TrimPrefix(ext, "."))
+	return strings.TrimSuffix(param, ext), format, ok
+}
+
+func feedExt(format feed.Format) string {
+	if format == feed.FormatRSS {
+		return ""
+	}
+	return "." + string(format)
+}
+
+// baseURL returns the configured public base URL, or reconstructs the
+// scheme and host the client used when none is configured.
+func (s *Server) baseURL(c *gin.Context) string {
+	if s.cfg.PublicBaseURL != "" {
+		return s.cfg.PublicBaseURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
@@ -0,0 +1,158 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+)
+
+const (
+	// defaultFeedItems is served when neither the request nor the account
+	// sets a limit.
+	defaultFeedItems = 50
+	// maxFeedFilter bounds the length of an include or exclude filter.
+	maxFeedFilter = 200
+)
+
+var errFeedRegexp = errors.New("regular expression filters can only be saved on the account")
+
+// feedOptions overlays the limit, summary_only, include, exclude and
+// first_only query parameters on the saved defaults. Feed URLs are public,
+// so the query may only filter by keywords; /regex/ filters are reserved
+// to the options editors save on an account.
+func (s *Server) feedOptions(query url.Values, defaults models.FeedOptions) (models.FeedOptions, error) {
+	opts := defaults
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return opts, errors.New("invalid limit")
+		}
+		opts.Limit = n
+	}
+	if query.Has("summary_only") {
+		opts.SummaryOnly = queryBool(query.Get("summary_only"))
+	}
+	if query.Has("first_only") {
+		opts.FirstOnly = queryBool(query.Get("first_only"))
+	}
+	for _, param := range []string{"include", "exclude"} {
+		if _, ok := feedRegexp(query.Get(param)); ok {
+			return opts, errFeedRegexp
+		}
+	}
+	if query.Has("include") {
+		opts.Include = query.Get("include")
+	}
+	if query.Has("exclude") {
+		opts.Exclude = query.Get("exclude")
+	}
+	if err := validateFeedOptions(opts); err != nil {
+		return opts, err
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = defaultFeedItems
+	}
+	if s.cfg.FeedMaxItems > 0 && opts.Limit > s.cfg.FeedMaxItems {
+		opts.Limit = s.cfg.FeedMaxItems
+	}
+	return opts, nil
+}
+
+func validateFeedOptions(opts models.FeedOptions) error {
+	if opts.Limit < 0 {
+		return errors.New("invalid limit")
+	}
+	for _, filter := range []string{opts.Include, opts.Exclude} {
+		if len(filter) > maxFeedFilter {
+			return fmt.Errorf("filter longer than %d characters", maxFeedFilter)
+		}
+		if pattern, ok := feedRegexp(filter); ok {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid filter %s: %v", filter, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyFeedOptions restricts an article query to the options' filters.
+// Regular expressions are evaluated by PostgreSQL, which accepts the
+// common subset of Go's syntax including a leading (?i).
+func applyFeedOptions(query *gorm.DB, opts models.FeedOptions) *gorm.DB {
+	if opts.FirstOnly {
+		query = query.Where("articles.item_index <= 1")
+	}
+	if cond, args := feedFilter(opts.Include); cond != "" {
+		query = query.Where(cond, args...)
+	}
+	if cond, args := feedFilter(opts.Exclude); cond != "" {
+		query = query.Where("NOT ("+cond+")", args...)
+	}
+	return query.Limit(opts.Limit)
+}
+
+// feedFilter turns a filter into a condition matching title or author.
+func feedFilter(filter string) (string, []any) {
+	if pattern, ok := feedRegexp(filter); ok {
+		return "(articles.title ~ ? OR articles.author ~ ?)", []any{pattern, pattern}
+	}
+	var (
+		conds []string
+		args  []any
+	)
+	for _, keyword := range strings.Split(filter, ",") {
+		keyword = strings.TrimSpace(keyword)
+		if keyword == "" {
+			continue
+		}
+		like := "%" + escapeLike(keyword) + "%"
+		conds = append(conds, "articles.title ILIKE ? OR articles.author ILIKE ?")
+		args = append(args, like, like)
+	}
+	if len(conds) == 0 {
+		return "", nil
+	}
+	return "(" + strings.Join(conds, " OR ") + ")", args
+}
+
+// feedRegexp reports whether filter is written as /pattern/.
+func feedRegexp(filter string) (string, bool) {
+	filter = strings.TrimSpace(filter)
+	if len(filter) < 2 || filter[0] != '/' || filter[len(filter)-1] != '/' {
+		return "", false
+	}
+	return filter[1 : len(filter)-1], true
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
+
+// queryBool treats a bare parameter such as ?summary_only as true.
+func queryBool(value string) bool {
+	if value == "" {
+		return true
+	}
+	b, _ := strconv.ParseBool(value)
+	return b
+}
+
+// feedCacheable reports whether a feed request may be served from and
+// stored in the feed cache. Keyword filters in the query can take any
+// value, so such requests are rendered fresh instead of growing the cache.
+func feedCacheable(query url.Values) bool {
+	return !query.Has("include") && !query.Has("exclude")
+}
+
+// feedOptionsKey distinguishes cached renderings of the same feed.
+func feedOptionsKey(opts models.FeedOptions) string {
+	return fmt.Sprintf("%d|%t|%t|%q|%q", opts.Limit, opts.SummaryOnly, opts.FirstOnly, opts.Include, opts.Exclude)
+}
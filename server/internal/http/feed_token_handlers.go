@@ -0,0 +1,149 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/util"
+)
+
+type feedTokenRequest struct {
+	AccountID *uint  `json:"account_id"`
+	Name      string `json:"name"`
+}
+
+func (s *Server) handleListFeedTokens(c *gin.Context) {
+	query := s.db.Order("id desc")
+	if accountID := c.Query("account_id"); accountID != "" {
+		query = query.Where("account_id = ?", accountID)
+	}
+	var tokens []models.FeedToken
+	if err := query.Find(&tokens).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list feed tokens")
+		return
+	}
+	result := make([]feedTokenView, 0, len(tokens))
+	for i := range tokens {
+		result = append(result, s.toFeedTokenView(c, &tokens[i]))
+	}
+	respondOK(c, apiData{"tokens": result})
+}
+
+func (s *Server) handleCreateFeedToken(c *gin.Context) {
+	var req feedTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.AccountID != nil {
+		if _, err := s.findAccount(strconv.Itoa(int(*req.AccountID))); err != nil {
+			respondError(c, http.StatusNotFound, "account not found")
+			return
+		}
+	}
+
+	value, err := util.RandHex(16)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	token := models.FeedToken{
+		AccountID: req.AccountID,
+		Name:      req.Name,
+		Token:     value,
+	}
+	if err := s.db.Create(&token).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create feed token")
+		return
+	}
+	auditChange(c, token.ID, nil, feedTokenAudit(&token))
+	respondOK(c, apiData{"token": s.toFeedTokenView(c, &token)})
+}
+
+func (s *Server) handleRotateFeedToken(c *gin.Context) {
+	token, err := s.findFeedToken(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "feed token not found")
+		return
+	}
+	value, err := util.RandHex(16)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to generate token")
+		return
+	}
+	token.Token = value
+	token.LastFetchedAt = nil
+	token.LastUserAgent = ""
+	token.LastIP = ""
+	if err := s.db.Save(token).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to rotate feed token")
+		return
+	}
+	auditChange(c, token.ID, nil, feedTokenAudit(token))
+	respondOK(c, apiData{"token": s.toFeedTokenView(c, token)})
+}
+
+func (s *Server) handleDeleteFeedToken(c *gin.Context) {
+	token, err := s.findFeedToken(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "feed token not found")
+		return
+	}
+	if err := s.db.Delete(token).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete feed token")
+		return
+	}
+	auditChange(c, token.ID, feedTokenAudit(token), nil)
+	respondOK(c, apiData{"deleted": token.ID})
+}
+
+// feedTokenAudit describes a feed token for the audit log, leaving out the
+// token itself.
+func feedTokenAudit(t *models.FeedToken) apiData {
+	return apiData{"account_id": t.AccountID, "name": t.Name}
+}
+
+func (s *Server) findFeedToken(idParam string) (*models.FeedToken, error) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, err
+	}
+	var token models.FeedToken
+	if err := s.db.First(&token, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+type feedTokenView struct {
+	ID            uint       `json:"id"`
+	AccountID     *uint      `json:"account_id"`
+	Name          string     `json:"name"`
+	Token         string     `json:"token"`
+	FeedURL       string     `json:"feed_url,omitempty"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	LastUserAgent string     `json:"last_user_agent"`
+	LastIP        string     `json:"last_ip"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func (s *Server) toFeedTokenView(c *gin.Context, t *models.FeedToken) feedTokenView {
+	view := feedTokenView{
+		ID:            t.ID,
+		AccountID:     t.AccountID,
+		Name:          t.Name,
+		Token:         t.Token,
+		LastFetchedAt: t.LastFetchedAt,
+		LastUserAgent: t.LastUserAgent,
+		LastIP:        t.LastIP,
+		CreatedAt:     t.CreatedAt,
+	}
+	if t.AccountID != nil {
+		view.FeedURL = s.baseURL(c) + "/feed/t/" + t.Token
+	}
+	return view
+}
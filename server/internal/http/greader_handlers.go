@@ -0,0 +1,653 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/feed"
+	"wechat2rss/internal/models"
+)
+
+// Stream and item identifiers of the Google Reader API.
+const (
+	readerItemPrefix   = "tag:google.com,2005:reader/item/"
+	streamReadingList  = "user/-/state/com.google/reading-list"
+	streamRead         = "user/-/state/com.google/read"
+	streamStarred      = "user/-/state/com.google/starred"
+	streamKeptUnread   = "user/-/state/com.google/kept-unread"
+	streamLabelPrefix  = "user/-/label/"
+	streamFeedPrefix   = "feed/"
+	readerDefaultCount = 20
+	readerMaxCount     = 1000
+)
+
+// readerAPIPath is where the authenticated reader API is mounted.
+const readerAPIPath = "/greader/reader/api/0"
+
+var errUnknownStream = errors.New("unknown stream")
+
+// registerReaderRoutes mounts a Google Reader compatible API under
+// /greader, the base URL to configure in clients such as Reeder,
+// NetNewsWire or FeedMe.
+func (s *Server) registerReaderRoutes() {
+	greader := s.engine.Group("/greader")
+	greader.Use(s.auditRequests)
+	greader.GET("/accounts/ClientLogin", s.handleReaderLogin)
+	greader.POST("/accounts/ClientLogin", s.handleReaderLogin)
+
+	api := greader.Group(strings.TrimPrefix(readerAPIPath, "/greader"))
+	api.Use(s.requireReaderAuth)
+	{
+		api.GET("/token", s.handleReaderToken)
+		api.GET("/user-info", s.handleReaderUserInfo)
+		api.GET("/subscription/list", s.handleReaderSubscriptions)
+		api.POST("/subscription/edit", s.handleReaderEditSubscription)
+		api.GET("/tag/list", s.handleReaderTags)
+		api.GET("/unread-count", s.handleReaderUnreadCount)
+		api.GET("/stream/items/ids", s.handleReaderItemIDs)
+		api.GET("/stream/items/contents", s.handleReaderItemContents)
+		api.POST("/stream/items/contents", s.handleReaderItemContents)
+		api.GET("/stream/contents/*stream", s.handleReaderStreamContents)
+		api.POST("/edit-tag", s.handleReaderEditTag)
+		api.POST("/mark-all-as-read", s.handleReaderMarkAllRead)
+	}
+}
+
+func (s *Server) handleReaderLogin(c *gin.Context) {
+	username := readerParam(c, "Email")
+	password := readerParam(c, "Passwd")
+
+	if wait := s.loginLocked(c, username); wait > 0 {
+		c.String(http.StatusTooManyRequests, "Error=TooManyAttempts\n")
+		return
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "username = ?", username).Error; err != nil || !s.readerPasswordOK(c, &user, password) {
+		s.loginFailed(c, username, models.LoginReasonBadCredentials)
+		c.String(http.StatusUnauthorized, "Error=BadAuthentication\n")
+		return
+	}
+	if user.Disabled {
+		s.loginFailed(c, username, models.LoginReasonDisabled)
+		c.String(http.StatusForbidden, "Error=AccountDisabled\n")
+		return
+	}
+	s.throttle.Succeed(username)
+	if user.ForceReset {
+		c.String(http.StatusForbidden, "Error=PasswordResetRequired\n")
+		return
+	}
+
+	c.Set("user_id", user.ID)
+	c.Set("user", &user)
+	auth := s.readerAuthToken(&user)
+	c.String(http.StatusOK, "SID=%s\nLSID=%s\nAuth=%s\n", auth, auth, auth)
+}
+
+// readerPasswordOK checks the ClientLogin password. Reader apps cannot ask
+// for a TOTP code, so users with two-factor enabled sign in with one of
+// their personal API tokens instead of the password.
+func (s *Server) readerPasswordOK(c *gin.Context, user *models.User, password string) bool {
+	if !user.TOTPEnabled {
+		return user.CheckPassword(password)
+	}
+	owner, _, err := s.authenticateAPIToken(c, password)
+	return err == nil && owner.ID == user.ID
+}
+
+// readerAuthToken derives the ClientLogin token of user. It embeds no
+// state: changing the password, enabling two-factor or changing
+// SESSION_SECRET revokes every token.
+func (s *Server) readerAuthToken(user *models.User) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.SessionSecret))
+	fmt.Fprintf(mac, "greader:%d:%s:%t", user.ID, user.PasswordHash, user.TOTPEnabled)
+	return user.Username + "/" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *Server) requireReaderAuth(c *gin.Context) {
+	header := c.GetHeader("Authorization")
+	token, ok := strings.CutPrefix(header, "GoogleLogin auth=")
+	if !ok {
+		c.String(http.StatusUnauthorized, "Unauthorized")
+		c.Abort()
+		return
+	}
+	i := strings.LastIndexByte(token, '/')
+	if i < 0 {
+		c.String(http.StatusUnauthorized, "Unauthorized")
+		c.Abort()
+		return
+	}
+
+	var user models.User
+	if err := s.db.First(&user, "username = ?", token[:i]).Error; err != nil ||
+		!hmac.Equal([]byte(token), []byte(s.readerAuthToken(&user))) || user.ForceReset || user.Disabled {
+		c.String(http.StatusUnauthorized, "Unauthorized")
+		c.Abort()
+		return
+	}
+	c.Set("user_id", user.ID)
+	c.Set("user", &user)
+	c.Set("reader_user", &user)
+	c.Next()
+}
+
+func readerUser(c *gin.Context) *models.User {
+	return c.MustGet("reader_user").(*models.User)
+}
+
+// handleReaderToken returns the edit token clients echo as T. Requests
+// are authenticated by header rather than cookie, so it is not checked.
+func (s *Server) handleReaderToken(c *gin.Context) {
+	sum := sha256.Sum256([]byte(s.readerAuthToken(readerUser(c))))
+	c.String(http.StatusOK, hex.EncodeToString(sum[:16]))
+}
+
+func (s *Server) handleReaderUserInfo(c *gin.Context) {
+	user := readerUser(c)
+	id := strconv.Itoa(int(user.ID))
+	c.JSON(http.StatusOK, gin.H{
+		"userId":        id,
+		"userName":      user.Username,
+		"userProfileId": id,
+		"userEmail":     user.Username,
+	})
+}
+
+type readerCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type readerSubscription struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Categories []readerCategory `json:"categories"`
+	URL        string           `json:"url"`
+	HTMLURL    string           `json:"htmlUrl"`
+	IconURL    string           `json:"iconUrl"`
+}
+
+func (s *Server) handleReaderSubscriptions(c *gin.Context) {
+	var accounts []models.Account
+	if err := s.db.Order("name").Find(&accounts).Error; err != nil {
+		c.String(http.StatusInternalServerError, "failed to list subscriptions")
+		return
+	}
+	base := s.baseURL(c)
+	subs := make([]readerSubscription, 0, len(accounts))
+	for i := range accounts {
+		account := &accounts[i]
+		sub := readerSubscription{
+			ID:         streamFeedPrefix + strconv.Itoa(int(account.ID)),
+			Title:      account.Name,
+			Categories: []readerCategory{},
+			URL:        base + "/feed/" + strconv.Itoa(int(account.ID)),
+			HTMLURL:    feed.ProfileURL(account),
+		}
+		if account.GroupName != "" {
+			sub.Categories = append(sub.Categories, readerCategory{ID: streamLabelPrefix + account.GroupName, Label: account.GroupName})
+		}
+		subs = append(subs, sub)
+	}
+	c.JSON(http.StatusOK, gin.H{"subscriptions": subs})
+}
+
+// handleReaderEditSubscription supports renaming accounts and moving them
+// between groups. Accounts can only be added in Wechat2RSS itself since
+// they need a WeChat login to crawl. Accounts are shared, so like the
+// console this is reserved to editors.
+func (s *Server) handleReaderEditSubscription(c *gin.Context) {
+	if !readerUser(c).HasRole(models.RoleEditor) {
+		c.String(http.StatusForbidden, "editor role required")
+		return
+	}
+	if action := readerParam(c, "ac"); action != "edit" {
+		c.String(http.StatusBadRequest, "only ac=edit is supported")
+		return
+	}
+	stream, ok := strings.CutPrefix(readerParam(c, "s"), streamFeedPrefix)
+	if !ok {
+		c.String(http.StatusBadRequest, "invalid stream")
+		return
+	}
+	account, err := s.findAccount(stream)
+	if err != nil {
+		c.String(http.StatusNotFound, "subscription not found")
+		return
+	}
+
+	updates := map[string]any{}
+	if title := readerParam(c, "t"); title != "" {
+		updates["name"] = title
+	}
+	if label, ok := strings.CutPrefix(normalizeStream(readerParam(c, "r")), streamLabelPrefix); ok && label == account.GroupName {
+		updates["group_name"] = ""
+	}
+	if label, ok := strings.CutPrefix(normalizeStream(readerParam(c, "a")), streamLabelPrefix); ok {
+		updates["group_name"] = label
+	}
+	if len(updates) > 0 {
+		before := toAccountView(account)
+		if err := s.db.Model(account).Updates(updates).Error; err != nil {
+			c.String(http.StatusInternalServerError, "failed to update subscription")
+			return
+		}
+		s.feeds.InvalidateAccount(account.ID)
+		auditChange(c, account.ID, before, toAccountView(account))
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+func (s *Server) handleReaderTags(c *gin.Context) {
+	var groups []string
+	if err := s.db.Model(&models.Account{}).
+		Where("group_name <> ''").
+		Distinct().
+		Order("group_name").
+		Pluck("group_name", &groups).Error; err != nil {
+		c.String(http.StatusInternalServerError, "failed to list tags")
+		return
+	}
+	tags := []gin.H{{"id": streamStarred}}
+	for _, group := range groups {
+		tags = append(tags, gin.H{"id": streamLabelPrefix + group, "type": "folder"})
+	}
+	c.JSON(http.StatusOK, gin.H{"tags": tags})
+}
+
+func (s *Server) handleReaderUnreadCount(c *gin.Context) {
+	user := readerUser(c)
+	counts, err := s.unreadCounts(user.ID)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to count unread items")
+		return
+	}
+	var accounts []models.Account
+	if err := s.db.Select("id", "group_name").Find(&accounts).Error; err != nil {
+		c.String(http.StatusInternalServerError, "failed to count unread items")
+		return
+	}
+
+	entry := func(id string, count int, newest time.Time) gin.H {
+		return gin.H{"id": id, "count": count, "newestItemTimestampUsec": strconv.FormatInt(newest.UnixMicro(), 10)}
+	}
+	var (
+		result []gin.H
+		total  unreadCount
+	)
+	labels := map[string]*unreadCount{}
+	for _, account := range accounts {
+		count, ok := counts[account.ID]
+		if !ok {
+			continue
+		}
+		result = append(result, entry(streamFeedPrefix+strconv.Itoa(int(account.ID)), count.Count, count.Newest))
+		addUnread(&total, count)
+		if account.GroupName != "" {
+			if labels[account.GroupName] == nil {
+				labels[account.GroupName] = &unreadCount{}
+			}
+			addUnread(labels[account.GroupName], count)
+		}
+	}
+	for name, count := range labels {
+		result = append(result, entry(streamLabelPrefix+name, count.Count, count.Newest))
+	}
+	result = append(result, entry(streamReadingList, total.Count, total.Newest))
+	c.JSON(http.StatusOK, gin.H{"max": readerMaxCount, "unreadcounts": result})
+}
+
+func addUnread(sum *unreadCount, count unreadCount) {
+	sum.Count += count.Count
+	if count.Newest.After(sum.Newest) {
+		sum.Newest = count.Newest
+	}
+}
+
+func (s *Server) handleReaderItemIDs(c *gin.Context) {
+	user := readerUser(c)
+	query, err := s.readerStream(user.ID, normalizeStream(readerParam(c, "s")))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	articles, continuation, err := s.readerPage(c, user.ID, query.Select("articles.id", "articles.published_at"))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	refs := make([]gin.H, 0, len(articles))
+	for _, article := range articles {
+		refs = append(refs, gin.H{
+			"id":              strconv.Itoa(int(article.ID)),
+			"directStreamIds": []string{},
+			"timestampUsec":   strconv.FormatInt(article.PublishedAt.UnixMicro(), 10),
+		})
+	}
+	resp := gin.H{"itemRefs": refs}
+	if continuation != "" {
+		resp["continuation"] = continuation
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) handleReaderStreamContents(c *gin.Context) {
+	user := readerUser(c)
+	stream := strings.TrimPrefix(c.Param("stream"), "/")
+	if stream == "" {
+		stream = readerParam(c, "s")
+	}
+	stream = normalizeStream(stream)
+	query, err := s.readerStream(user.ID, stream)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	articles, continuation, err := s.readerPage(c, user.ID, query)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	items, err := s.readerItems(c, user.ID, articles)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load items")
+		return
+	}
+	resp := gin.H{
+		"direction": "ltr",
+		"id":        stream,
+		"title":     stream,
+		"self":      []gin.H{{"href": s.baseURL(c) + c.Request.URL.RequestURI()}},
+		"updated":   time.Now().Unix(),
+		"items":     items,
+	}
+	if continuation != "" {
+		resp["continuation"] = continuation
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) handleReaderItemContents(c *gin.Context) {
+	user := readerUser(c)
+	ids, err := readerItemIDs(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	var articles []models.Article
+	if len(ids) > 0 {
+		if err := s.db.Where("id IN ?", ids).Order("published_at desc, id desc").Find(&articles).Error; err != nil {
+			c.String(http.StatusInternalServerError, "failed to load items")
+			return
+		}
+	}
+	items, err := s.readerItems(c, user.ID, articles)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "failed to load items")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"direction": "ltr",
+		"id":        streamReadingList,
+		"updated":   time.Now().Unix(),
+		"items":     items,
+	})
+}
+
+func (s *Server) handleReaderEditTag(c *gin.Context) {
+	user := readerUser(c)
+	ids, err := readerItemIDs(c)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	// ids of missing articles are ignored rather than failing the batch
+	var existing []uint
+	if err := s.db.Model(&models.Article{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		c.String(http.StatusInternalServerError, "failed to load items")
+		return
+	}
+	ids = existing
+
+	apply := func(tags []string, add bool) error {
+		for _, tag := range tags {
+			var err error
+			switch normalizeStream(tag) {
+			case streamRead:
+				err = s.setArticleState(user.ID, ids, stateRead, add)
+			case streamKeptUnread:
+				err = s.setArticleState(user.ID, ids, stateRead, !add)
+			case streamStarred:
+				err = s.setArticleState(user.ID, ids, stateStarred, add)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := apply(readerParams(c, "a"), true); err != nil {
+		c.String(http.StatusInternalServerError, "failed to update items")
+		return
+	}
+	if err := apply(readerParams(c, "r"), false); err != nil {
+		c.String(http.StatusInternalServerError, "failed to update items")
+		return
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// handleReaderMarkAllRead marks a stream read up to ts, given in
+// microseconds, so items arriving after the client's view stay unread.
+func (s *Server) handleReaderMarkAllRead(c *gin.Context) {
+	user := readerUser(c)
+	query, err := s.readerStream(user.ID, normalizeStream(readerParam(c, "s")))
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	if ts, err := strconv.ParseInt(readerParam(c, "ts"), 10, 64); err == nil && ts > 0 {
+		query = query.Where("articles.published_at <= ?", time.UnixMicro(ts))
+	}
+	if _, err := s.setQueryState(user.ID, withState(query, user.ID, stateRead, false), stateRead, true); err != nil {
+		c.String(http.StatusInternalServerError, "failed to update items")
+		return
+	}
+	c.String(http.StatusOK, "OK")
+}
+
+// readerStream returns the article query behind a stream id.
+func (s *Server) readerStream(userID uint, stream string) (*gorm.DB, error) {
+	query := s.db.Model(&models.Article{})
+	switch {
+	case stream == streamReadingList:
+		return query, nil
+	case stream == streamStarred:
+		return withState(query, userID, stateStarred, true), nil
+	case stream == streamRead:
+		return withState(query, userID, stateRead, true), nil
+	case strings.HasPrefix(stream, streamFeedPrefix):
+		id, err := strconv.Atoi(strings.TrimPrefix(stream, streamFeedPrefix))
+		if err != nil {
+			return nil, errUnknownStream
+		}
+		return query.Where("articles.account_id = ?", id), nil
+	case strings.HasPrefix(stream, streamLabelPrefix):
+		return s.groupScope(strings.TrimPrefix(stream, streamLabelPrefix))(query), nil
+	}
+	return nil, errUnknownStream
+}
+
+// readerPage applies the common stream parameters: n (count), r=o
+// (oldest first), ot/nt (time bounds in seconds), xt/it (exclude or
+// require a state) and c (continuation).
+func (s *Server) readerPage(c *gin.Context, userID uint, query *gorm.DB) ([]models.Article, string, error) {
+	count := readerDefaultCount
+	if n, err := strconv.Atoi(readerParam(c, "n")); err == nil && n > 0 {
+		count = min(n, readerMaxCount)
+	}
+	if ot, err := strconv.ParseInt(readerParam(c, "ot"), 10, 64); err == nil && ot > 0 {
+		query = query.Where("articles.published_at >= ?", time.Unix(ot, 0))
+	}
+	if nt, err := strconv.ParseInt(readerParam(c, "nt"), 10, 64); err == nil && nt > 0 {
+		query = query.Where("articles.published_at < ?", time.Unix(nt, 0))
+	}
+	for _, target := range readerParams(c, "xt") {
+		if flag, ok := readerStateFlag(target); ok {
+			query = withState(query, userID, flag, false)
+		}
+	}
+	for _, target := range readerParams(c, "it") {
+		if flag, ok := readerStateFlag(target); ok {
+			query = withState(query, userID, flag, true)
+		}
+	}
+	cursor, err := decodeArticleCursor(readerParam(c, "c"))
+	if err != nil {
+		return nil, "", err
+	}
+	return findArticlePage(query, cursor, readerParam(c, "r") == "o", count)
+}
+
+func readerStateFlag(stream string) (string, bool) {
+	switch normalizeStream(stream) {
+	case streamRead:
+		return stateRead, true
+	case streamStarred:
+		return stateStarred, true
+	}
+	return "", false
+}
+
+type readerHref struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type readerItem struct {
+	ID            string       `json:"id"`
+	CrawlTimeMsec string       `json:"crawlTimeMsec"`
+	TimestampUsec string       `json:"timestampUsec"`
+	Published     int64        `json:"published"`
+	Updated       int64        `json:"updated"`
+	Title         string       `json:"title"`
+	Author        string       `json:"author,omitempty"`
+	Canonical     []readerHref `json:"canonical"`
+	Alternate     []readerHref `json:"alternate"`
+	Summary       gin.H        `json:"summary"`
+	Categories    []string     `json:"categories"`
+	Origin        gin.H        `json:"origin"`
+}
+
+func (s *Server) readerItems(c *gin.Context, userID uint, articles []models.Article) ([]readerItem, error) {
+	states, err := s.articleStates(userID, articles)
+	if err != nil {
+		return nil, err
+	}
+	accounts, err := s.accountsOf(articles)
+	if err != nil {
+		return nil, err
+	}
+	base := s.baseURL(c)
+
+	items := make([]readerItem, 0, len(articles))
+	for _, article := range articles {
+		body := article.ContentHTML
+		if body == "" {
+			body = article.Summary
+		}
+		item := readerItem{
+			ID:            fmt.Sprintf("%s%016x", readerItemPrefix, article.ID),
+			CrawlTimeMsec: strconv.FormatInt(article.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(article.PublishedAt.UnixMicro(), 10),
+			Published:     article.PublishedAt.Unix(),
+			Updated:       article.UpdatedAt.Unix(),
+			Title:         article.Title,
+			Author:        article.Author,
+			Canonical:     []readerHref{{Href: article.RawURL}},
+			Alternate:     []readerHref{{Href: article.RawURL, Type: "text/html"}},
+			Summary:       gin.H{"direction": "ltr", "content": body},
+			Categories:    []string{streamReadingList},
+		}
+		stream := streamFeedPrefix + strconv.Itoa(int(article.AccountID))
+		origin := gin.H{"streamId": stream, "htmlUrl": base + "/feed/" + strconv.Itoa(int(article.AccountID))}
+		if account := accounts[article.AccountID]; account != nil {
+			origin["title"] = account.Name
+			origin["htmlUrl"] = feed.ProfileURL(account)
+			if account.GroupName != "" {
+				item.Categories = append(item.Categories, streamLabelPrefix+account.GroupName)
+			}
+		}
+		item.Origin = origin
+		if state, ok := states[article.ID]; ok {
+			if state.Read {
+				item.Categories = append(item.Categories, streamRead)
+			}
+			if state.Starred {
+				item.Categories = append(item.Categories, streamStarred)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// readerItemIDs parses the i parameters, which clients send either as
+// long tag URIs with a hex id or as the decimal short form.
+func readerItemIDs(c *gin.Context) ([]uint, error) {
+	var ids []uint
+	for _, raw := range readerParams(c, "i") {
+		var (
+			id  uint64
+			err error
+		)
+		if hexID, ok := strings.CutPrefix(raw, readerItemPrefix); ok {
+			id, err = strconv.ParseUint(hexID, 16, 64)
+		} else {
+			id, err = strconv.ParseUint(raw, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid item id %s", raw)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// normalizeStream rewrites user/<id>/... to the user/-/... form.
+func normalizeStream(stream string) string {
+	if rest, ok := strings.CutPrefix(stream, "user/"); ok {
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			return "user/-" + rest[i:]
+		}
+	}
+	return stream
+}
+
+// readerParam reads a parameter from the form body or the query string;
+// clients use both.
+func readerParam(c *gin.Context, key string) string {
+	if v, ok := c.GetPostForm(key); ok {
+		return v
+	}
+	return c.Query(key)
+}
+
+func readerParams(c *gin.Context, key string) []string {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values
+	}
+	return c.QueryArray(key)
+}
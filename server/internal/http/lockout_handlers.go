@@ -0,0 +1,139 @@
+package http
+
+import (
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+)
+
+// loginLocked reports how long the username or client IP of a login
+// attempt is still locked out, setting Retry-After when it is.
+func (s *Server) loginLocked(c *gin.Context, username string) time.Duration {
+	wait, err := s.throttle.Locked(username, c.ClientIP())
+	if err != nil {
+		log.Printf("login lockout check error: %v", err)
+		return 0
+	}
+	if wait > 0 {
+		s.throttle.Fail(username, c.ClientIP(), c.Request.UserAgent(), models.LoginReasonLocked)
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	}
+	return wait
+}
+
+// loginFailed records a rejected login and holds the response back by the
+// progressive delay, slowing down password guessing.
+func (s *Server) loginFailed(c *gin.Context, username, reason string) {
+	delay := s.throttle.Fail(username, c.ClientIP(), c.Request.UserAgent(), reason)
+	if delay <= 0 {
+		return
+	}
+	select {
+	case <-time.After(delay):
+	case <-c.Request.Context().Done():
+	}
+}
+
+type loginLockoutView struct {
+	ID            uint       `json:"id"`
+	Scope         string     `json:"scope"`
+	Key           string     `json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+	Locked        bool       `json:"locked"`
+}
+
+func toLoginLockoutView(l *models.LoginLockout) loginLockoutView {
+	return loginLockoutView{
+		ID:            l.ID,
+		Scope:         l.Scope,
+		Key:           l.Key,
+		Failures:      l.Failures,
+		LastFailureAt: l.LastFailureAt,
+		LockedUntil:   l.LockedUntil,
+		Locked:        l.LockedUntil != nil && l.LockedUntil.After(time.Now()),
+	}
+}
+
+type loginAttemptView struct {
+	ID        uint      `json:"id"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handleListLoginLockouts lists failure counters, active lockouts first.
+// ?locked=true restricts the list to active lockouts.
+func (s *Server) handleListLoginLockouts(c *gin.Context) {
+	query := s.db.Model(&models.LoginLockout{})
+	if locked, _ := strconv.ParseBool(c.Query("locked")); locked {
+		query = query.Where("locked_until > ?", time.Now())
+	}
+	var lockouts []models.LoginLockout
+	if err := query.Order("locked_until DESC NULLS LAST").Order("last_failure_at DESC").Find(&lockouts).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list lockouts")
+		return
+	}
+	result := make([]loginLockoutView, 0, len(lockouts))
+	for i := range lockouts {
+		result = append(result, toLoginLockoutView(&lockouts[i]))
+	}
+	respondOK(c, apiData{"lockouts": result})
+}
+
+// handleDeleteLoginLockout clears a lockout together with its failure count.
+func (s *Server) handleDeleteLoginLockout(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "lockout not found")
+		return
+	}
+	result := s.db.Delete(&models.LoginLockout{}, "id = ?", id)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "failed to clear lockout")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, "lockout not found")
+		return
+	}
+	respondOK(c, apiData{"deleted": id})
+}
+
+// handleListLoginAttempts lists recent rejected logins, optionally for one
+// username or ip.
+func (s *Server) handleListLoginAttempts(c *gin.Context) {
+	query := s.db.Model(&models.LoginAttempt{})
+	if username := c.Query("username"); username != "" {
+		query = query.Where("username = ?", username)
+	}
+	if ip := c.Query("ip"); ip != "" {
+		query = query.Where("ip = ?", ip)
+	}
+	var attempts []models.LoginAttempt
+	if err := query.Order("created_at DESC").Limit(pageSize(c.Query("limit"))).Find(&attempts).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list login attempts")
+		return
+	}
+	result := make([]loginAttemptView, 0, len(attempts))
+	for _, a := range attempts {
+		result = append(result, loginAttemptView{
+			ID:        a.ID,
+			Username:  a.Username,
+			IP:        a.IP,
+			UserAgent: a.UserAgent,
+			Reason:    a.Reason,
+			CreatedAt: a.CreatedAt,
+		})
+	}
+	respondOK(c, apiData{"attempts": result})
+}
@@ -0,0 +1,240 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/feed"
+	"wechat2rss/internal/models"
+)
+
+// maxOPMLSize caps uploaded OPML documents. The request body may exceed it
+// by maxOPMLFormOverhead for the multipart framing around the file.
+const (
+	maxOPMLSize         = 5 << 20
+	maxOPMLFormOverhead = 64 << 10
+)
+
+func (s *Server) handleExportOPML(c *gin.Context) {
+	query := s.db.Order("group_name, name")
+	if group := c.Query("group"); group != "" {
+		query = query.Where("group_name = ?", group)
+	}
+	var accounts []models.Account
+	if err := query.Find(&accounts).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list accounts")
+		return
+	}
+
+	var tokens []models.FeedToken
+	if err := s.db.Where("account_id IS NOT NULL").Order("id").Find(&tokens).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list feed tokens")
+		return
+	}
+	tokenOf := make(map[uint]string)
+	for _, t := range tokens {
+		if _, ok := tokenOf[*t.AccountID]; !ok {
+			tokenOf[*t.AccountID] = t.Token
+		}
+	}
+
+	base := s.baseURL(c)
+	groups := make(map[string][]feed.Outline)
+	for i := range accounts {
+		account := &accounts[i]
+		xmlURL := base + "/feed/" + strconv.Itoa(int(account.ID))
+		if token, ok := tokenOf[account.ID]; ok {
+			xmlURL = base + "/feed/t/" + token
+		}
+		groups[account.GroupName] = append(groups[account.GroupName], feed.Outline{
+			Text:     account.Name,
+			Title:    account.Name,
+			Type:     "rss",
+			XMLURL:   xmlURL,
+			HTMLURL:  feed.ProfileURL(account),
+			BizID:    account.BizID,
+			WechatID: account.WechatID,
+		})
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var body []feed.Outline
+	for _, name := range names {
+		if name == "" {
+			body = append(body, groups[name]...)
+			continue
+		}
+		body = append(body, feed.Outline{Text: name, Title: name, Children: groups[name]})
+	}
+
+	var buf bytes.Buffer
+	if err := feed.WriteOPML(&buf, "Wechat2RSS", body); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to encode opml")
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="wechat2rss.opml"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", buf.Bytes())
+}
+
+// tooLarge reports whether err comes from a body over its MaxBytesReader
+// limit.
+func tooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+type opmlImportResult struct {
+	Title     string `json:"title"`
+	XMLURL    string `json:"xml_url"`
+	Group     string `json:"group"`
+	BizID     string `json:"biz_id"`
+	Status    string `json:"status"` // created, exists, skipped, failed
+	AccountID uint   `json:"account_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func (s *Server) handleImportOPML(c *gin.Context) {
+	// bound the body before the multipart form is parsed, which would
+	// otherwise buffer an upload of any size
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxOPMLSize+maxOPMLFormOverhead)
+	var reader io.Reader = io.LimitReader(c.Request.Body, maxOPMLSize)
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			respondError(c, http.StatusBadRequest, "failed to read upload")
+			return
+		}
+		defer f.Close()
+		reader = io.LimitReader(f, maxOPMLSize)
+	} else if tooLarge(err) {
+		respondError(c, http.StatusRequestEntityTooLarge, "opml document too large")
+		return
+	}
+
+	doc, err := feed.ReadOPML(reader)
+	if err != nil {
+		if tooLarge(err) {
+			respondError(c, http.StatusRequestEntityTooLarge, "opml document too large")
+			return
+		}
+		respondError(c, http.StatusBadRequest, "invalid opml: "+err.Error())
+		return
+	}
+
+	var results []opmlImportResult
+	var walk func(outlines []feed.Outline, group string)
+	walk = func(outlines []feed.Outline, group string) {
+		for _, outline := range outlines {
+			if outline.XMLURL == "" && len(outline.Children) > 0 {
+				name := outline.Title
+				if name == "" {
+					name = outline.Text
+				}
+				walk(outline.Children, name)
+				continue
+			}
+			results = append(results, s.importOutline(outline, group))
+		}
+	}
+	walk(doc.Body, "")
+
+	summary := map[string]int{}
+	var created []uint
+	for _, r := range results {
+		summary[r.Status]++
+		if r.Status == "created" {
+			created = append(created, r.AccountID)
+		}
+	}
+	if len(created) > 0 {
+		s.auditImport(c, created)
+	}
+	respondOK(c, apiData{"results": results, "summary": summary})
+}
+
+// auditImport records the accounts an import created, targeting their
+// comma-separated IDs.
+func (s *Server) auditImport(c *gin.Context, ids []uint) {
+	var accounts []models.Account
+	if err := s.db.Where("id IN ?", ids).Order("id").Find(&accounts).Error; err != nil {
+		log.Printf("opml import audit error: %v", err)
+		return
+	}
+	target := make([]string, 0, len(accounts))
+	views := make([]*accountView, 0, len(accounts))
+	for i := range accounts {
+		target = append(target, strconv.Itoa(int(accounts[i].ID)))
+		views = append(views, toAccountView(&accounts[i]))
+	}
+	auditChange(c, strings.Join(target, ","), nil, apiData{"created": views})
+}
+
+// importOutline creates the account an outline refers to unless one with
+// the same biz id already exists.
+func (s *Server) importOutline(outline feed.Outline, group string) opmlImportResult {
+	title := outline.Title
+	if title == "" {
+		title = outline.Text
+	}
+	result := opmlImportResult{Title: title, XMLURL: outline.XMLURL, Group: group}
+
+	biz := outline.BizID
+	if biz == "" {
+		biz = feed.BizFromURL(outline.XMLURL)
+	}
+	if biz == "" {
+		biz = feed.BizFromURL(outline.HTMLURL)
+	}
+	result.BizID = biz
+	if biz == "" {
+		result.Status = "skipped"
+		result.Error = "no __biz found in outline"
+		return result
+	}
+
+	var existing models.Account
+	err := s.db.First(&existing, "biz_id = ?", biz).Error
+	if err == nil {
+		result.Status = "exists"
+		result.AccountID = existing.ID
+		return result
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+
+	wechatID := outline.WechatID
+	if wechatID == "" {
+		wechatID = biz
+	}
+	account := models.Account{
+		Name:      title,
+		WechatID:  wechatID,
+		BizID:     biz,
+		GroupName: group,
+		Status:    defaultStatus(""),
+	}
+	if err := s.db.Create(&account).Error; err != nil {
+		result.Status = "failed"
+		result.Error = err.Error()
+		return result
+	}
+	result.Status = "created"
+	result.AccountID = account.ID
+	return result
+}
@@ -0,0 +1,99 @@
+package http
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// articleCursor marks a position in an article listing ordered by
+// (published_at, id) descending.
+type articleCursor struct {
+	PublishedAt time.Time
+	ID          uint
+}
+
+func (cur articleCursor) encode() string {
+	raw := fmt.Sprintf("%d:%d", cur.PublishedAt.UnixNano(), cur.ID)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeArticleCursor(value string) (*articleCursor, error) {
+	if value == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	return &articleCursor{PublishedAt: time.Unix(0, nanos), ID: uint(id)}, nil
+}
+
+// applyArticleCursor orders query by (published_at, id), newest first
+// unless asc is set, and skips rows up to and including cur.
+func applyArticleCursor(query *gorm.DB, cur *articleCursor, asc bool) *gorm.DB {
+	dir, cmp := "desc", "<"
+	if asc {
+		dir, cmp = "asc", ">"
+	}
+	query = query.Order("articles.published_at " + dir).Order("articles.id " + dir)
+	if cur != nil {
+		query = query.Where("(articles.published_at, articles.id) "+cmp+" (?, ?)", cur.PublishedAt, cur.ID)
+	}
+	return query
+}
+
+// findArticlePage loads up to limit articles after cur and returns the
+// cursor for the following page, empty when this is the last one.
+func findArticlePage(query *gorm.DB, cur *articleCursor, asc bool, limit int) ([]models.Article, string, error) {
+	var articles []models.Article
+	if err := applyArticleCursor(query, cur, asc).Limit(limit + 1).Find(&articles).Error; err != nil {
+		return nil, "", err
+	}
+	if len(articles) <= limit {
+		return articles, "", nil
+	}
+	articles = articles[:limit]
+	last := articles[limit-1]
+	return articles, articleCursor{PublishedAt: last.PublishedAt, ID: last.ID}.encode(), nil
+}
+
+// pageSize reads the limit query value, clamped to maxPageSize.
+func pageSize(value string) int {
+	size, err := strconv.Atoi(value)
+	if err != nil || size <= 0 {
+		return defaultPageSize
+	}
+	return min(size, maxPageSize)
+}
+
+// parseDate accepts either a calendar date or an RFC 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,124 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+)
+
+type savedSearchRequest struct {
+	Name  string `json:"name" binding:"required"`
+	Query string `json:"query" binding:"required"`
+	Group string `json:"group"`
+}
+
+func (s *Server) handleListSavedSearches(c *gin.Context) {
+	var searches []models.SavedSearch
+	if err := s.db.Order("id desc").Find(&searches).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list saved searches")
+		return
+	}
+	result := make([]savedSearchView, 0, len(searches))
+	for i := range searches {
+		result = append(result, s.toSavedSearchView(c, &searches[i]))
+	}
+	respondOK(c, apiData{"saved_searches": result})
+}
+
+func (s *Server) handleCreateSavedSearch(c *gin.Context) {
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	saved := models.SavedSearch{
+		Name:      req.Name,
+		Query:     req.Query,
+		GroupName: req.Group,
+	}
+	if err := s.db.Create(&saved).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to create saved search")
+		return
+	}
+	view := s.toSavedSearchView(c, &saved)
+	auditChange(c, saved.ID, nil, view)
+	respondOK(c, apiData{"saved_search": view})
+}
+
+func (s *Server) handleUpdateSavedSearch(c *gin.Context) {
+	saved, err := s.findSavedSearch(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "saved search not found")
+		return
+	}
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	before := s.toSavedSearchView(c, saved)
+	saved.Name = req.Name
+	saved.Query = req.Query
+	saved.GroupName = req.Group
+	if err := s.db.Save(saved).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to update saved search")
+		return
+	}
+	s.feeds.InvalidateAggregates()
+	view := s.toSavedSearchView(c, saved)
+	auditChange(c, saved.ID, before, view)
+	respondOK(c, apiData{"saved_search": view})
+}
+
+func (s *Server) handleDeleteSavedSearch(c *gin.Context) {
+	saved, err := s.findSavedSearch(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "saved search not found")
+		return
+	}
+	if err := s.db.Delete(saved).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete saved search")
+		return
+	}
+	s.feeds.InvalidateAggregates()
+	auditChange(c, saved.ID, s.toSavedSearchView(c, saved), nil)
+	respondOK(c, apiData{"deleted": saved.ID})
+}
+
+func (s *Server) findSavedSearch(idParam string) (*models.SavedSearch, error) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, err
+	}
+	var saved models.SavedSearch
+	if err := s.db.First(&saved, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+type savedSearchView struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Query     string    `json:"query"`
+	Group     string    `json:"group"`
+	FeedURL   string    `json:"feed_url"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (s *Server) toSavedSearchView(c *gin.Context, saved *models.SavedSearch) savedSearchView {
+	return savedSearchView{
+		ID:        saved.ID,
+		Name:      saved.Name,
+		Query:     saved.Query,
+		Group:     saved.GroupName,
+		FeedURL:   s.baseURL(c) + "/feed/search/" + strconv.Itoa(int(saved.ID)),
+		CreatedAt: saved.CreatedAt,
+		UpdatedAt: saved.UpdatedAt,
+	}
+}
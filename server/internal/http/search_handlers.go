@@ -0,0 +1,70 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/content"
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
+)
+
+type searchHit struct {
+	articleView
+	TitleHTML string `json:"title_html"`
+	Snippet   string `json:"snippet"`
+}
+
+func (s *Server) handleSearchArticles(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		respondError(c, http.StatusBadRequest, "q required")
+		return
+	}
+	cursor, err := decodeArticleCursor(c.Query("cursor"))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	limit := pageSize(c.Query("limit"))
+
+	query, err := s.filterArticles(c, s.search.Match(s.db.Model(&models.Article{}), q))
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	articles, nextCursor, err := findArticlePage(query, cursor, false, limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	views, err := s.articleViews(c, articles)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "search failed")
+		return
+	}
+	hits := make([]searchHit, 0, len(articles))
+	for i := range articles {
+		view := views[i]
+		view.ContentHTML = ""
+		body := articles[i].ContentText
+		if body == "" {
+			body = content.PlainText(articles[i].ContentHTML)
+		}
+		text := articles[i].Summary + "\n" + body
+		hits = append(hits, searchHit{
+			articleView: view,
+			TitleHTML:   search.Snippet(articles[i].Title, q),
+			Snippet:     search.Snippet(text, q),
+		})
+	}
+
+	respondOK(c, apiData{
+		"results":     hits,
+		"next_cursor": nextCursor,
+	})
+}
@@ -4,20 +4,24 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
-	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
-	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"wechat2rss/internal/config"
+	"wechat2rss/internal/feed"
 	"wechat2rss/internal/models"
+	"wechat2rss/internal/search"
 	"wechat2rss/internal/service"
+	"wechat2rss/internal/sessionstore"
+	"wechat2rss/internal/websub"
 	"wechat2rss/internal/wechat"
 )
 
@@ -30,16 +34,42 @@ type Server struct {
 	engine *gin.Engine
 	http   *http.Server
 	wechat *wechat.Manager
+	search *search.Engine
+	feeds  *feed.Cache
+
+	sessions     *sessionstore.Store
+	websubClient *http.Client
+	// websubPending holds a slot for each intent verification in flight
+	websubPending chan struct{}
+	throttle      *service.LoginThrottle
 }
 
 // New constructs the HTTP server and routes.
-func New(cfg *config.Config, db *gorm.DB, wm *wechat.Manager) *Server {
+func New(cfg *config.Config, db *gorm.DB, wm *wechat.Manager, engine *search.Engine, feeds *feed.Cache) *Server {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// X-Forwarded-For is only honoured from TRUSTED_PROXIES; with none
+	// configured ClientIP is the peer address.
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		panic(fmt.Sprintf("trusted proxies: %v", err))
+	}
 	router.Use(gin.Recovery())
-	router.Use(cors.Default())
-
-	store := cookie.NewStore([]byte(cfg.SessionSecret))
+	router.Use(corsMiddleware(cfg.CORSOrigins))
+
+	store := sessionstore.New(db,
+		time.Duration(cfg.SessionIdle)*time.Minute,
+		time.Duration(cfg.SessionMaxAge)*time.Hour,
+		sessions.Options{
+			Path:     "/",
+			Domain:   cfg.CookieDomain,
+			Secure:   cfg.CookieSecure,
+			HttpOnly: true,
+			SameSite: sameSiteMode(cfg.CookieSameSite),
+		})
+	router.Use(func(c *gin.Context) {
+		c.Request = c.Request.WithContext(sessionstore.WithClientIP(c.Request.Context(), c.ClientIP()))
+		c.Next()
+	})
 	router.Use(sessions.Sessions(sessionName, store))
 
 	s := &Server{
@@ -47,6 +77,18 @@ func New(cfg *config.Config, db *gorm.DB, wm *wechat.Manager) *Server {
 		db:     db,
 		engine: router,
 		wechat: wm,
+		search: engine,
+		feeds:  feeds,
+
+		sessions:      store,
+		websubClient:  websub.GuardedClient(20 * time.Second),
+		websubPending: make(chan struct{}, max(cfg.WebSubMaxPending, 1)),
+		throttle: service.NewLoginThrottle(db, cfg.LoginMaxFailures, cfg.LoginMaxIPFails,
+			time.Duration(cfg.LoginWindow)*time.Minute, time.Duration(cfg.LoginLockout)*time.Minute),
+	}
+	if cfg.WebSubPrivate {
+		// subscribers on the local network are trusted explicitly
+		s.websubClient = &http.Client{Timeout: 20 * time.Second}
 	}
 
 	if err := service.EnsureAdmin(db, cfg.AdminUser, cfg.AdminPassword); err != nil {
@@ -65,10 +107,19 @@ func New(cfg *config.Config, db *gorm.DB, wm *wechat.Manager) *Server {
 func (s *Server) registerRoutes() {
 	s.engine.GET("/health", s.handleHealth)
 	s.engine.GET("/feed/:id", s.handleFeed)
+	s.engine.GET("/feed/:id/archive/:month", s.handleFeedArchive)
+	s.engine.GET("/feed/t/:token", s.handleTokenFeed)
+	s.engine.GET("/feed/t/:token/archive/:month", s.handleTokenFeedArchive)
+	s.engine.GET("/feed/group/:name", s.handleGroupFeed)
+	s.engine.GET("/feed/search/:id", s.handleSavedSearchFeed)
+	s.engine.POST(websubPath, s.handleWebSubHub)
 
 	api := s.engine.Group("/api")
+	api.Use(s.requireCSRF, s.auditRequests)
 	{
+		api.GET("/csrf", s.handleCSRFToken)
 		api.POST("/login", s.handleLogin)
+		api.POST("/login/2fa", s.handleLoginTwoFactor)
 
 		auth := api.Group("/")
 		auth.Use(s.requireSession)
@@ -78,30 +129,133 @@ func (s *Server) registerRoutes() {
 			auth.POST("/password", s.handlePasswordUpdate)
 		}
 
+		userSessions := api.Group("/sessions")
+		userSessions.Use(s.requireSession, s.requireSessionAuth)
+		{
+			userSessions.GET("", s.handleListSessions)
+			userSessions.DELETE("", s.handleRevokeSessions)
+			userSessions.DELETE("/:id", s.handleRevokeSession)
+		}
+
+		tokens := api.Group("/tokens")
+		tokens.Use(s.requireSession, s.requireSessionAuth, s.requirePasswordSet, s.requireTwoFactor)
+		{
+			tokens.GET("", s.handleListAPITokens)
+			tokens.POST("", s.handleCreateAPIToken)
+			tokens.DELETE("/:id", s.handleDeleteAPIToken)
+		}
+
+		twoFactor := api.Group("/2fa")
+		twoFactor.Use(s.requireSession, s.requireSessionAuth, s.requirePasswordSet)
+		{
+			twoFactor.GET("", s.handleTwoFactorStatus)
+			twoFactor.POST("/setup", s.handleTwoFactorSetup)
+			twoFactor.POST("/enable", s.handleTwoFactorEnable)
+			twoFactor.POST("/disable", s.handleTwoFactorDisable)
+			twoFactor.POST("/recovery-codes", s.handleRegenerateRecoveryCodes)
+		}
+
 		secured := api.Group("/")
-		secured.Use(s.requireSession, s.requirePasswordSet)
+		secured.Use(s.requireSession, s.requirePasswordSet, s.requireTwoFactor)
+
+		// viewers read articles and feeds and keep their own reading state
+		viewer := secured.Group("/")
+		{
+			viewer.GET("/accounts", s.handleListAccounts)
+			viewer.GET("/accounts/:id", s.handleGetAccount)
+			viewer.GET("/accounts/:id/articles", s.handleListArticles)
+			viewer.GET("/articles", s.handleListAllArticles)
+			viewer.GET("/articles/search", s.handleSearchArticles)
+			viewer.GET("/articles/:id", s.handleGetArticle)
+			viewer.GET("/articles/:id/revisions", s.handleListRevisions)
+			viewer.GET("/articles/:id/revisions/:rev", s.handleGetRevision)
+			viewer.GET("/articles/:id/diff", s.handleDiffRevisions)
+			viewer.GET("/articles/:id/state", s.handleGetArticleState)
+			viewer.PUT("/articles/:id/state", s.handleUpdateArticleState)
+			viewer.POST("/articles/mark-read", s.handleMarkRead)
+			viewer.GET("/tags", s.handleListTags)
+			viewer.GET("/saved-searches", s.handleListSavedSearches)
+		}
+
+		// editors manage accounts, crawling and feed distribution
+		editor := secured.Group("/")
+		editor.Use(s.requireRole(models.RoleEditor))
+		{
+			editor.POST("/accounts", s.handleCreateAccount)
+			editor.PUT("/accounts/:id", s.handleUpdateAccount)
+			editor.DELETE("/accounts/:id", s.handleDeleteAccount)
+
+			editor.GET("/opml", s.handleExportOPML)
+			editor.POST("/opml", s.handleImportOPML)
+
+			editor.POST("/accounts/:id/tasks", s.handleCreateTask)
+			editor.GET("/tasks", s.handleListTasks)
+			editor.GET("/tasks/:id/logs", s.handleTaskLogs)
+
+			editor.GET("/feed-tokens", s.handleListFeedTokens)
+			editor.POST("/feed-tokens", s.handleCreateFeedToken)
+			editor.POST("/feed-tokens/:id/rotate", s.handleRotateFeedToken)
+			editor.DELETE("/feed-tokens/:id", s.handleDeleteFeedToken)
+
+			editor.POST("/saved-searches", s.handleCreateSavedSearch)
+			editor.PUT("/saved-searches/:id", s.handleUpdateSavedSearch)
+			editor.DELETE("/saved-searches/:id", s.handleDeleteSavedSearch)
+
+			editor.GET("/alerts", s.handleListAlerts)
+			editor.POST("/alerts", s.handleUpdateAlerts)
+
+			editor.GET("/wechat/sessions", s.handleListWechatSessions)
+			editor.POST("/wechat/sessions", s.handleCreateWechatSession)
+			editor.GET("/wechat/sessions/:id", s.handleGetWechatSession)
+			editor.GET("/wechat/search", s.handleWechatSearch)
+		}
+
+		admin := secured.Group("/")
+		admin.Use(s.requireRole(models.RoleAdmin))
 		{
-			secured.GET("/accounts", s.handleListAccounts)
-			secured.POST("/accounts", s.handleCreateAccount)
-			secured.GET("/accounts/:id", s.handleGetAccount)
-			secured.PUT("/accounts/:id", s.handleUpdateAccount)
-			secured.DELETE("/accounts/:id", s.handleDeleteAccount)
-
-			secured.POST("/accounts/:id/tasks", s.handleCreateTask)
-			secured.GET("/accounts/:id/articles", s.handleListArticles)
-			secured.GET("/tasks", s.handleListTasks)
-			secured.GET("/tasks/:id/logs", s.handleTaskLogs)
-
-			secured.GET("/wechat/sessions", s.handleListWechatSessions)
-			secured.POST("/wechat/sessions", s.handleCreateWechatSession)
-			secured.GET("/wechat/sessions/:id", s.handleGetWechatSession)
-			secured.GET("/wechat/search", s.handleWechatSearch)
+			admin.GET("/users", s.handleListUsers)
+			admin.POST("/users", s.handleCreateUser)
+			admin.PUT("/users/:id", s.handleUpdateUser)
+			admin.DELETE("/users/:id", s.handleDeleteUser)
+			admin.GET("/users/:id/sessions", s.handleListUserSessions)
+			admin.DELETE("/users/:id/sessions", s.handleRevokeUserSessions)
+
+			admin.GET("/audit", s.handleListAudit)
+
+			admin.GET("/alert-channels", s.handleListAlertChannels)
+			admin.POST("/alert-channels", s.handleCreateAlertChannel)
+			admin.PUT("/alert-channels/:id", s.handleUpdateAlertChannel)
+			admin.DELETE("/alert-channels/:id", s.handleDeleteAlertChannel)
+			admin.POST("/alert-channels/:id/test", s.handleTestAlertChannel)
+
+			admin.GET("/settings", s.handleGetSettings)
+			admin.PUT("/settings", s.handleUpdateSettings)
+
+			admin.GET("/login-lockouts", s.handleListLoginLockouts)
+			admin.DELETE("/login-lockouts/:id", s.handleDeleteLoginLockout)
+			admin.GET("/login-attempts", s.handleListLoginAttempts)
+
+			admin.GET("/websub/subscriptions", s.handleListWebSubSubscriptions)
+			admin.DELETE("/websub/subscriptions/:id", s.handleDeleteWebSubSubscription)
 		}
 	}
 
+	s.registerReaderRoutes()
 	s.registerStaticRoutes()
 }
 
+// sameSiteMode maps SESSION_COOKIE_SAMESITE to its cookie attribute.
+func sameSiteMode(value string) http.SameSite {
+	switch value {
+	case "strict":
+		return http.SameSiteStrictMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteLaxMode
+	}
+}
+
 // Run starts the HTTP server.
 func (s *Server) Run() error {
 	return s.http.ListenAndServe()
@@ -117,10 +271,15 @@ func (s *Server) handleHealth(c *gin.Context) {
 }
 
 type loginRequest struct {
-	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Username     string `json:"username" binding:"required"`
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
 }
 
+// handleLogin checks the password. Users with two-factor enabled either
+// send their code along, or get a pending login to finish through
+// POST /api/login/2fa.
 func (s *Server) handleLogin(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -128,42 +287,130 @@ func (s *Server) handleLogin(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := s.db.First(&user, "username = ?", req.Username).Error; err != nil {
-		respondError(c, http.StatusUnauthorized, "invalid credentials")
+	if wait := s.loginLocked(c, req.Username); wait > 0 {
+		respondError(c, http.StatusTooManyRequests, "too many failed logins, try again later")
 		return
 	}
 
-	if !user.CheckPassword(req.Password) {
+	var user models.User
+	if err := s.db.First(&user, "username = ?", req.Username).Error; err != nil || !user.CheckPassword(req.Password) {
+		s.loginFailed(c, req.Username, models.LoginReasonBadCredentials)
 		respondError(c, http.StatusUnauthorized, "invalid credentials")
 		return
 	}
+	if user.Disabled {
+		s.loginFailed(c, req.Username, models.LoginReasonDisabled)
+		respondError(c, http.StatusForbidden, "account disabled")
+		return
+	}
+
+	if user.TOTPEnabled {
+		if req.Code == "" && req.RecoveryCode == "" {
+			s.beginTwoFactorLogin(c, &user)
+			return
+		}
+		ok, err := s.verifySecondFactor(&user, req.Code, req.RecoveryCode)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to verify code")
+			return
+		}
+		if !ok {
+			s.loginFailed(c, req.Username, models.LoginReasonBadCode)
+			respondError(c, http.StatusUnauthorized, errInvalidCode.Error())
+			return
+		}
+	}
+	s.completeLogin(c, &user)
+}
+
+// completeLogin starts the session of an authenticated user.
+func (s *Server) completeLogin(c *gin.Context, user *models.User) {
+	s.throttle.Succeed(user.Username)
 
 	session := sessions.Default(c)
+	session.Delete(pendingUserKey)
+	session.Delete(pendingAtKey)
 	session.Set("user_id", user.ID)
 	if err := session.Save(); err != nil {
 		respondError(c, http.StatusInternalServerError, "failed to persist session")
 		return
 	}
+	c.Set("user_id", user.ID)
+	c.Set("user", user)
 
+	setup, err := s.needsTwoFactorSetup(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
 	respondOK(c, apiData{
-		"id":            user.ID,
-		"username":      user.Username,
-		"require_reset": user.ForceReset,
+		"id":                 user.ID,
+		"username":           user.Username,
+		"role":               user.Role,
+		"require_reset":      user.ForceReset,
+		"two_factor_enabled": user.TOTPEnabled,
+		"require_two_factor": setup,
 	})
 }
 
+// requireSession loads the logged-in user, either from the cookie session
+// or from a personal API token sent as a Bearer credential. Sessions of
+// users who were disabled or deleted since logging in are rejected.
 func (s *Server) requireSession(c *gin.Context) {
+	if value, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		user, token, err := s.authenticateAPIToken(c, strings.TrimSpace(value))
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, err.Error())
+			c.Abort()
+			return
+		}
+		if !tokenAllows(token, c) {
+			respondError(c, http.StatusForbidden, errTokenScope.Error())
+			c.Abort()
+			return
+		}
+		c.Set("user_id", user.ID)
+		c.Set("user", user)
+		c.Set("api_token", token)
+		c.Next()
+		return
+	}
+
 	session := sessions.Default(c)
 	if session.Get("user_id") == nil {
 		respondError(c, http.StatusUnauthorized, "unauthorized")
 		c.Abort()
 		return
 	}
-	c.Set("user_id", session.Get("user_id"))
+	var user models.User
+	if err := s.db.First(&user, "id = ?", session.Get("user_id")).Error; err != nil || user.Disabled {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		c.Abort()
+		return
+	}
+	c.Set("user_id", user.ID)
+	c.Set("user", &user)
 	c.Next()
 }
 
+// requireRole rejects users whose role does not include role.
+func (s *Server) requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := s.currentUser(c)
+		if err != nil {
+			respondError(c, http.StatusUnauthorized, "invalid session")
+			c.Abort()
+			return
+		}
+		if !user.HasRole(role) {
+			respondError(c, http.StatusForbidden, "insufficient role")
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func (s *Server) requirePasswordSet(c *gin.Context) {
 	user, err := s.currentUser(c)
 	if err != nil {
@@ -190,11 +437,19 @@ func (s *Server) handleMe(c *gin.Context) {
 		return
 	}
 
+	setup, err := s.needsTwoFactorSetup(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
 	respondOK(c, apiData{
-		"id":            user.ID,
-		"username":      user.Username,
-		"require_reset": user.ForceReset,
-		"created_at":    user.CreatedAt,
+		"id":                 user.ID,
+		"username":           user.Username,
+		"role":               user.Role,
+		"require_reset":      user.ForceReset,
+		"two_factor_enabled": user.TOTPEnabled,
+		"require_two_factor": setup,
+		"created_at":         user.CreatedAt,
 	})
 }
 
@@ -241,6 +496,10 @@ func (s *Server) handlePasswordUpdate(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "failed to save user")
 		return
 	}
+	// sessions signed in with the old password end, except this one
+	if err := s.revokeSessions(user.ID, sessions.Default(c).ID()); err != nil {
+		log.Printf("revoke sessions of user %d error: %v", user.ID, err)
+	}
 
 	respondOK(c, apiData{"status": "password_updated"})
 }
@@ -271,6 +530,9 @@ func (s *Server) registerStaticRoutes() {
 }
 
 func (s *Server) currentUser(c *gin.Context) (*models.User, error) {
+	if user, ok := c.Get("user"); ok {
+		return user.(*models.User), nil
+	}
 	idVal, exists := c.Get("user_id")
 	if !exists {
 		session := sessions.Default(c)
@@ -0,0 +1,133 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/sessionstore"
+)
+
+type sessionView struct {
+	ID         uint      `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
+
+// listSessions returns the active sessions of userID, marking the one of
+// the current request.
+func (s *Server) listSessions(c *gin.Context, userID uint) ([]sessionView, error) {
+	var rows []models.UserSession
+	if err := s.sessions.Active(s.db.Where("user_id = ?", userID)).
+		Order("last_seen_at DESC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	current := currentSessionHash(c)
+	result := make([]sessionView, 0, len(rows))
+	for _, row := range rows {
+		result = append(result, sessionView{
+			ID:         row.ID,
+			IP:         row.IP,
+			UserAgent:  row.UserAgent,
+			CreatedAt:  row.CreatedAt,
+			LastSeenAt: row.LastSeenAt,
+			ExpiresAt:  row.ExpiresAt,
+			Current:    row.TokenHash == current,
+		})
+	}
+	return result, nil
+}
+
+// currentSessionHash is the stored hash of the request's session, or ""
+// for requests without one.
+func currentSessionHash(c *gin.Context) string {
+	if id := sessions.Default(c).ID(); id != "" {
+		return sessionstore.HashToken(id)
+	}
+	return ""
+}
+
+// revokeSessions ends every session of userID except the one whose token
+// is keep.
+func (s *Server) revokeSessions(userID uint, keep string) error {
+	query := s.db.Where("user_id = ?", userID)
+	if keep != "" {
+		query = query.Where("token_hash <> ?", sessionstore.HashToken(keep))
+	}
+	return query.Delete(&models.UserSession{}).Error
+}
+
+func (s *Server) handleListSessions(c *gin.Context) {
+	result, err := s.listSessions(c, sessionUserID(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	respondOK(c, apiData{"sessions": result})
+}
+
+// handleRevokeSession ends one of the caller's sessions, possibly the
+// current one.
+func (s *Server) handleRevokeSession(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "session not found")
+		return
+	}
+	result := s.db.Where("user_id = ?", sessionUserID(c)).Delete(&models.UserSession{}, id)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, "failed to revoke session")
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, "session not found")
+		return
+	}
+	respondOK(c, apiData{"deleted": id})
+}
+
+// handleRevokeSessions signs the caller out everywhere else.
+func (s *Server) handleRevokeSessions(c *gin.Context) {
+	if err := s.revokeSessions(sessionUserID(c), sessions.Default(c).ID()); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	respondOK(c, apiData{"status": "revoked"})
+}
+
+func (s *Server) handleListUserSessions(c *gin.Context) {
+	user, err := s.findUser(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	result, err := s.listSessions(c, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list sessions")
+		return
+	}
+	respondOK(c, apiData{"sessions": result})
+}
+
+// handleRevokeUserSessions signs a user out of every session, for instance
+// after a suspected compromise.
+func (s *Server) handleRevokeUserSessions(c *gin.Context) {
+	user, err := s.findUser(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	if err := s.revokeSessions(user.ID, ""); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	respondOK(c, apiData{"status": "revoked"})
+}
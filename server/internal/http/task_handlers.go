@@ -31,6 +31,7 @@ func (s *Server) handleCreateTask(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, "failed to update account task")
 		return
 	}
+	auditChange(c, account.ID, nil, apiData{"task_id": task.ID})
 
 	respondOK(c, apiData{
 		"task": task,
@@ -0,0 +1,468 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/service"
+	"wechat2rss/internal/totp"
+	"wechat2rss/internal/util"
+)
+
+const (
+	totpIssuer = "Wechat2RSS"
+	// pendingLoginTTL bounds the time between password and code.
+	pendingLoginTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+
+	pendingUserKey = "pending_user_id"
+	pendingAtKey   = "pending_at"
+)
+
+var (
+	errInvalidCode      = errors.New("invalid two-factor code")
+	errTwoFactorEnabled = errors.New("two-factor authentication already enabled")
+)
+
+// beginTwoFactorLogin remembers a user who passed the password check so
+// handleLoginTwoFactor can finish the login.
+func (s *Server) beginTwoFactorLogin(c *gin.Context, user *models.User) {
+	session := sessions.Default(c)
+	session.Delete("user_id")
+	session.Set(pendingUserKey, user.ID)
+	session.Set(pendingAtKey, time.Now().Unix())
+	if err := session.Save(); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to persist session")
+		return
+	}
+	respondOK(c, apiData{"two_factor_required": true})
+}
+
+type loginTwoFactorRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// handleLoginTwoFactor is the second login step, taking either a TOTP code
+// or one of the user's recovery codes.
+func (s *Server) handleLoginTwoFactor(c *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Code == "" && req.RecoveryCode == "" {
+		respondError(c, http.StatusBadRequest, "code or recovery_code is required")
+		return
+	}
+
+	session := sessions.Default(c)
+	userID, _ := session.Get(pendingUserKey).(uint)
+	startedAt, _ := session.Get(pendingAtKey).(int64)
+	if userID == 0 || time.Since(time.Unix(startedAt, 0)) > pendingLoginTTL {
+		respondError(c, http.StatusUnauthorized, "login expired, sign in again")
+		return
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", userID).Error; err != nil || user.Disabled || !user.TOTPEnabled {
+		respondError(c, http.StatusUnauthorized, "login expired, sign in again")
+		return
+	}
+
+	if wait := s.loginLocked(c, user.Username); wait > 0 {
+		respondError(c, http.StatusTooManyRequests, "too many failed logins, try again later")
+		return
+	}
+	ok, err := s.verifySecondFactor(&user, req.Code, req.RecoveryCode)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to verify code")
+		return
+	}
+	if !ok {
+		s.loginFailed(c, user.Username, models.LoginReasonBadCode)
+		respondError(c, http.StatusUnauthorized, errInvalidCode.Error())
+		return
+	}
+	s.completeLogin(c, &user)
+}
+
+// verifySecondFactor accepts a current TOTP code, which cannot be replayed,
+// or an unused recovery code, which is used up.
+func (s *Server) verifySecondFactor(user *models.User, code, recovery string) (bool, error) {
+	if code != "" {
+		counter, ok := totp.Validate(user.TOTPSecret, code, time.Now(), user.TOTPCounter)
+		if !ok {
+			return false, nil
+		}
+		// the condition on the old counter keeps concurrent requests from
+		// both accepting the same code
+		result := s.db.Model(&models.User{}).
+			Where("id = ? AND totp_counter < ?", user.ID, counter).
+			Update("totp_counter", counter)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		user.TOTPCounter = counter
+		return result.RowsAffected == 1, nil
+	}
+	if recovery != "" {
+		result := s.db.Model(&models.RecoveryCode{}).
+			Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.ID, hashRecoveryCode(recovery)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected == 1, nil
+	}
+	return false, nil
+}
+
+// needsTwoFactorSetup reports an admin who has to enrol before using the
+// console because two-factor is mandatory for admins.
+func (s *Server) needsTwoFactorSetup(user *models.User) (bool, error) {
+	if user.TOTPEnabled || user.Role != models.RoleAdmin {
+		return false, nil
+	}
+	return service.SettingBool(s.db, models.SettingRequireAdmin2FA)
+}
+
+// requireTwoFactor blocks admins who have not enrolled while two-factor is
+// mandatory for them; only the /api/2fa endpoints stay open.
+func (s *Server) requireTwoFactor(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		c.Abort()
+		return
+	}
+	setup, err := s.needsTwoFactorSetup(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		c.Abort()
+		return
+	}
+	if setup {
+		respondError(c, http.StatusForbidden, "two-factor enrollment required")
+		c.Abort()
+		return
+	}
+	c.Next()
+}
+
+func (s *Server) handleTwoFactorStatus(c *gin.Context) {
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		return
+	}
+	var remaining int64
+	if err := s.db.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to count recovery codes")
+		return
+	}
+	setup, err := s.needsTwoFactorSetup(user)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	respondOK(c, apiData{
+		"enabled":             user.TOTPEnabled,
+		"pending":             !user.TOTPEnabled && user.TOTPSecret != "",
+		"required":            setup,
+		"recovery_codes_left": remaining,
+	})
+}
+
+type twoFactorSetupRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// handleTwoFactorSetup starts enrolment with a fresh secret. It only takes
+// effect once a code from the authenticator app is confirmed through
+// handleTwoFactorEnable.
+func (s *Server) handleTwoFactorSetup(c *gin.Context) {
+	var req twoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		return
+	}
+	if !user.CheckPassword(req.Password) {
+		respondError(c, http.StatusForbidden, "password incorrect")
+		return
+	}
+	if user.TOTPEnabled {
+		respondError(c, http.StatusConflict, errTwoFactorEnabled.Error())
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to generate secret")
+		return
+	}
+	if err := s.db.Model(user).Updates(map[string]any{
+		"totp_secret":  secret,
+		"totp_counter": 0,
+	}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to save secret")
+		return
+	}
+	respondOK(c, apiData{
+		"secret": secret,
+		"uri":    totp.URI(totpIssuer, user.Username, secret),
+	})
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// handleTwoFactorEnable confirms enrolment with a first code and returns
+// the recovery codes, which are shown only this once.
+func (s *Server) handleTwoFactorEnable(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		return
+	}
+	if user.TOTPEnabled {
+		respondError(c, http.StatusConflict, errTwoFactorEnabled.Error())
+		return
+	}
+	if user.TOTPSecret == "" {
+		respondError(c, http.StatusConflict, "call /api/2fa/setup first")
+		return
+	}
+	counter, ok := totp.Validate(user.TOTPSecret, req.Code, time.Now(), user.TOTPCounter)
+	if !ok {
+		respondError(c, http.StatusUnauthorized, errInvalidCode.Error())
+		return
+	}
+
+	var codes []string
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(user).Updates(map[string]any{
+			"totp_enabled": true,
+			"totp_counter": counter,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to enable two-factor authentication")
+		return
+	}
+	respondOK(c, apiData{"enabled": true, "recovery_codes": codes})
+}
+
+type twoFactorDisableRequest struct {
+	Password     string `json:"password" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// handleTwoFactorDisable turns two-factor off after checking both the
+// password and a second factor.
+func (s *Server) handleTwoFactorDisable(c *gin.Context) {
+	var req twoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		return
+	}
+	if !user.TOTPEnabled {
+		respondError(c, http.StatusConflict, "two-factor authentication is not enabled")
+		return
+	}
+	if !user.CheckPassword(req.Password) {
+		respondError(c, http.StatusForbidden, "password incorrect")
+		return
+	}
+	if user.Role == models.RoleAdmin {
+		required, err := service.SettingBool(s.db, models.SettingRequireAdmin2FA)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to load settings")
+			return
+		}
+		if required {
+			respondError(c, http.StatusConflict, "two-factor authentication is mandatory for admins")
+			return
+		}
+	}
+	ok, err := s.verifySecondFactor(user, req.Code, req.RecoveryCode)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to verify code")
+		return
+	}
+	if !ok {
+		respondError(c, http.StatusUnauthorized, errInvalidCode.Error())
+		return
+	}
+
+	if err := resetTwoFactor(s.db, user.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to disable two-factor authentication")
+		return
+	}
+	respondOK(c, apiData{"enabled": false})
+}
+
+// handleRegenerateRecoveryCodes replaces all recovery codes, for instance
+// after running low.
+func (s *Server) handleRegenerateRecoveryCodes(c *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := s.currentUser(c)
+	if err != nil {
+		respondError(c, http.StatusUnauthorized, "invalid session")
+		return
+	}
+	if !user.TOTPEnabled {
+		respondError(c, http.StatusConflict, "two-factor authentication is not enabled")
+		return
+	}
+	ok, err := s.verifySecondFactor(user, req.Code, "")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to verify code")
+		return
+	}
+	if !ok {
+		respondError(c, http.StatusUnauthorized, errInvalidCode.Error())
+		return
+	}
+
+	codes, err := replaceRecoveryCodes(s.db, user.ID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to generate recovery codes")
+		return
+	}
+	respondOK(c, apiData{"recovery_codes": codes})
+}
+
+// replaceRecoveryCodes drops the recovery codes of userID and returns a
+// new set in plain text.
+func replaceRecoveryCodes(db *gorm.DB, userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for range recoveryCodeCount {
+		raw, err := util.RandHex(5)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		rows = append(rows, models.RecoveryCode{UserID: userID, CodeHash: hashRecoveryCode(code)})
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// hashRecoveryCode ignores case, spaces and dashes so codes can be typed
+// as printed or not.
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashAPIToken(code)
+}
+
+// resetTwoFactor turns two-factor off for userID and drops its secret and
+// recovery codes.
+func resetTwoFactor(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]any{
+			"totp_secret":  "",
+			"totp_enabled": false,
+			"totp_counter": 0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+type settingsView struct {
+	RequireAdmin2FA bool `json:"require_admin_2fa"`
+}
+
+func (s *Server) loadSettings() (settingsView, error) {
+	required, err := service.SettingBool(s.db, models.SettingRequireAdmin2FA)
+	if err != nil {
+		return settingsView{}, err
+	}
+	return settingsView{RequireAdmin2FA: required}, nil
+}
+
+func (s *Server) handleGetSettings(c *gin.Context) {
+	settings, err := s.loadSettings()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	respondOK(c, apiData{"settings": settings})
+}
+
+type updateSettingsRequest struct {
+	RequireAdmin2FA *bool `json:"require_admin_2fa"`
+}
+
+// handleUpdateSettings changes the settings present in the request.
+func (s *Server) handleUpdateSettings(c *gin.Context) {
+	var req updateSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	before, err := s.loadSettings()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	if req.RequireAdmin2FA != nil {
+		if err := service.SetSetting(s.db, models.SettingRequireAdmin2FA, strconv.FormatBool(*req.RequireAdmin2FA)); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to save settings")
+			return
+		}
+	}
+	after, err := s.loadSettings()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load settings")
+		return
+	}
+	auditChange(c, "", before, after)
+	respondOK(c, apiData{"settings": after})
+}
@@ -0,0 +1,220 @@
+package http
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/service"
+)
+
+var errLastAdmin = errors.New("at least one enabled admin is required")
+
+type userView struct {
+	ID         uint      `json:"id"`
+	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	Disabled   bool      `json:"disabled"`
+	ForceReset bool      `json:"require_reset"`
+	TwoFactor  bool      `json:"two_factor_enabled"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func toUserView(u *models.User) userView {
+	return userView{
+		ID:         u.ID,
+		Username:   u.Username,
+		Role:       u.Role,
+		Disabled:   u.Disabled,
+		ForceReset: u.ForceReset,
+		TwoFactor:  u.TOTPEnabled,
+		CreatedAt:  u.CreatedAt,
+		UpdatedAt:  u.UpdatedAt,
+	}
+}
+
+func (s *Server) handleListUsers(c *gin.Context) {
+	var users []models.User
+	if err := s.db.Order("id").Find(&users).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list users")
+		return
+	}
+	result := make([]userView, 0, len(users))
+	for i := range users {
+		result = append(result, toUserView(&users[i]))
+	}
+	respondOK(c, apiData{"users": result})
+}
+
+type createUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// handleCreateUser adds a user with an initial password they must change
+// on first login.
+func (s *Server) handleCreateUser(c *gin.Context) {
+	var req createUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !models.ValidRole(req.Role) {
+		respondError(c, http.StatusBadRequest, "invalid role")
+		return
+	}
+
+	user := models.User{Username: req.Username, Role: req.Role, ForceReset: true}
+	if err := user.SetPassword(req.Password); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to set password")
+		return
+	}
+	if err := s.db.Create(&user).Error; err != nil {
+		respondError(c, http.StatusConflict, "username already exists")
+		return
+	}
+	view := toUserView(&user)
+	auditChange(c, user.ID, nil, view)
+	respondOK(c, apiData{"user": view})
+}
+
+type updateUserRequest struct {
+	Role     *string `json:"role"`
+	Disabled *bool   `json:"disabled"`
+	Password *string `json:"password"`
+	Reset2FA bool    `json:"reset_2fa"`
+}
+
+// handleUpdateUser changes the role or enabled state of a user, or resets
+// their password, which forces another reset on their next login.
+// reset_2fa turns off two-factor for a user who lost their device.
+func (s *Server) handleUpdateUser(c *gin.Context) {
+	user, err := s.findUser(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	var req updateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	before := toUserView(user)
+
+	updates := map[string]any{}
+	if req.Role != nil {
+		if !models.ValidRole(*req.Role) {
+			respondError(c, http.StatusBadRequest, "invalid role")
+			return
+		}
+		updates["role"] = *req.Role
+	}
+	if req.Disabled != nil {
+		updates["disabled"] = *req.Disabled
+	}
+	if req.Password != nil {
+		if len(*req.Password) < 8 {
+			respondError(c, http.StatusBadRequest, "password must be at least 8 characters")
+			return
+		}
+		if err := user.SetPassword(*req.Password); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to set password")
+			return
+		}
+		updates["password_hash"] = user.PasswordHash
+		updates["force_reset"] = true
+	}
+
+	demoted := (req.Role != nil && *req.Role != models.RoleAdmin) || (req.Disabled != nil && *req.Disabled)
+	if demoted {
+		if err := s.keepAdmin(user); err != nil {
+			respondError(c, http.StatusConflict, err.Error())
+			return
+		}
+	}
+
+	if len(updates) > 0 {
+		if err := s.db.Model(user).Updates(updates).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to update user")
+			return
+		}
+	}
+	if req.Password != nil || (req.Disabled != nil && *req.Disabled) {
+		if err := s.revokeSessions(user.ID, ""); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to revoke sessions")
+			return
+		}
+	}
+	if req.Reset2FA {
+		if err := resetTwoFactor(s.db, user.ID); err != nil {
+			respondError(c, http.StatusInternalServerError, "failed to reset two-factor authentication")
+			return
+		}
+	}
+	if err := s.db.First(user, "id = ?", user.ID).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to load user")
+		return
+	}
+	view := toUserView(user)
+	auditChange(c, user.ID, before, view)
+	respondOK(c, apiData{"user": view})
+}
+
+func (s *Server) handleDeleteUser(c *gin.Context) {
+	user, err := s.findUser(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "user not found")
+		return
+	}
+	if user.ID == sessionUserID(c) {
+		respondError(c, http.StatusConflict, "cannot delete yourself")
+		return
+	}
+	if err := s.keepAdmin(user); err != nil {
+		respondError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if err := service.DeleteUser(s.db, user.ID); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete user")
+		return
+	}
+	if err := s.revokeSessions(user.ID, ""); err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to revoke sessions")
+		return
+	}
+	auditChange(c, user.ID, toUserView(user), nil)
+	respondOK(c, apiData{"deleted": user.ID})
+}
+
+// keepAdmin refuses to demote, disable or delete the last enabled admin.
+func (s *Server) keepAdmin(user *models.User) error {
+	if user.Role != models.RoleAdmin || user.Disabled {
+		return nil
+	}
+	admins, err := service.CountAdmins(s.db)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return errLastAdmin
+	}
+	return nil
+}
+
+func (s *Server) findUser(idParam string) (*models.User, error) {
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return nil, err
+	}
+	var user models.User
+	if err := s.db.First(&user, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,448 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/feed"
+	"wechat2rss/internal/models"
+	"wechat2rss/internal/websub"
+)
+
+const (
+	websubSelf     = "self"
+	websubPath     = "/websub"
+	minWebSubLease = 3600
+	maxSecretBytes = 200
+)
+
+var errUnknownTopic = errors.New("topic is not a feed served here")
+
+// topicFeed is a feed URL resolved for delivery through the built-in hub.
+type topicFeed struct {
+	accountID *uint
+	group     string
+	format    feed.Format
+	build     func() (*feed.Feed, error)
+}
+
+// hubURL returns the hub advertised in feeds served under base, or ""
+// when WebSub is disabled.
+func (s *Server) hubURL(base string) string {
+	switch s.cfg.WebSubHub {
+	case "":
+		return ""
+	case websubSelf:
+		return base + websubPath
+	default:
+		return s.cfg.WebSubHub
+	}
+}
+
+// handleWebSubHub implements the subscriber-facing endpoint of the
+// built-in hub. Intent is verified asynchronously, as the spec allows.
+func (s *Server) handleWebSubHub(c *gin.Context) {
+	if s.cfg.WebSubHub != websubSelf {
+		c.String(http.StatusNotFound, "hub disabled")
+		return
+	}
+	mode := c.PostForm("hub.mode")
+	topic := c.PostForm("hub.topic")
+	callback := c.PostForm("hub.callback")
+	secret := c.PostForm("hub.secret")
+
+	if mode != "subscribe" && mode != "unsubscribe" {
+		c.String(http.StatusBadRequest, "unsupported hub.mode")
+		return
+	}
+	u, err := url.Parse(callback)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		c.String(http.StatusBadRequest, "invalid hub.callback")
+		return
+	}
+	if !s.cfg.WebSubPrivate {
+		if err := websub.CheckCallback(c.Request.Context(), u.Hostname()); err != nil {
+			c.String(http.StatusBadRequest, "hub.callback must resolve to a public address")
+			return
+		}
+	}
+	if len(secret) >= maxSecretBytes {
+		c.String(http.StatusBadRequest, "hub.secret too long")
+		return
+	}
+	resolved, err := s.resolveTopic(topic)
+	if err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if mode == "subscribe" {
+		if ok, err := s.websubHostQuota(topic, callback, u.Hostname()); err != nil {
+			c.String(http.StatusInternalServerError, "failed to check subscriptions")
+			return
+		} else if !ok {
+			c.String(http.StatusTooManyRequests, "too many subscriptions for this callback host")
+			return
+		}
+	}
+
+	lease := s.cfg.WebSubLease
+	if v, err := strconv.Atoi(c.PostForm("hub.lease_seconds")); err == nil && v > 0 && v < lease {
+		lease = max(v, minWebSubLease)
+	}
+
+	// verification requests go out on behalf of unauthenticated callers,
+	// so only a bounded number may be in flight
+	select {
+	case s.websubPending <- struct{}{}:
+	default:
+		c.Header("Retry-After", "60")
+		c.String(http.StatusServiceUnavailable, "too many pending verifications")
+		return
+	}
+	go func() {
+		defer func() { <-s.websubPending }()
+		s.confirmSubscription(mode, topic, callback, secret, lease, resolved)
+	}()
+	c.Status(http.StatusAccepted)
+}
+
+func (s *Server) confirmSubscription(mode, topic, callback, secret string, lease int, resolved *topicFeed) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := websub.VerifyIntent(ctx, s.websubClient, callback, mode, topic, lease); err != nil {
+		log.Printf("websub %s %s for %s not verified: %v", mode, callback, topic, err)
+		return
+	}
+
+	if mode == "unsubscribe" {
+		if err := s.db.Where("topic = ? AND callback = ?", topic, callback).
+			Delete(&models.WebSubSubscription{}).Error; err != nil {
+			log.Printf("websub unsubscribe %s error: %v", callback, err)
+		}
+		return
+	}
+
+	var sub models.WebSubSubscription
+	err := s.db.First(&sub, "topic = ? AND callback = ?", topic, callback).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("websub subscribe %s error: %v", callback, err)
+		return
+	}
+	sub.Topic = topic
+	sub.Callback = callback
+	if u, err := url.Parse(callback); err == nil {
+		sub.CallbackHost = u.Hostname()
+	}
+	sub.Secret = secret
+	sub.AccountID = resolved.accountID
+	sub.GroupName = resolved.group
+	sub.ExpiresAt = time.Now().Add(time.Duration(lease) * time.Second)
+	sub.LastError = ""
+	if err := s.db.Save(&sub).Error; err != nil {
+		log.Printf("websub subscribe %s error: %v", callback, err)
+	}
+}
+
+// websubHostQuota reports whether a callback host may add a subscription
+// under WEBSUB_MAX_PER_HOST. Renewing an existing subscription is always
+// allowed.
+func (s *Server) websubHostQuota(topic, callback, host string) (bool, error) {
+	if s.cfg.WebSubMaxPerHost <= 0 {
+		return true, nil
+	}
+	var existing int64
+	if err := s.db.Model(&models.WebSubSubscription{}).
+		Where("topic = ? AND callback = ?", topic, callback).Count(&existing).Error; err != nil {
+		return false, err
+	}
+	if existing > 0 {
+		return true, nil
+	}
+	var count int64
+	if err := s.db.Model(&models.WebSubSubscription{}).
+		Where("callback_host = ?", host).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count < int64(s.cfg.WebSubMaxPerHost), nil
+}
+
+// resolveTopic maps a feed URL back to the feed it serves. Only account,
+// token, group and all-accounts feeds can be subscribed to; private
+// accounts need a token in the topic URL.
+func (s *Server) resolveTopic(topic string) (*topicFeed, error) {
+	u, err := url.Parse(topic)
+	if err != nil || !strings.HasPrefix(u.Path, "/feed/") {
+		return nil, errUnknownTopic
+	}
+	query := u.Query()
+	token, err := s.topicToken(query.Get("token"))
+	if err != nil {
+		return nil, err
+	}
+
+	rest := strings.TrimPrefix(u.Path, "/feed/")
+	dir, name := path.Split(rest)
+	name, format, ok := splitFeedParam(name, "")
+	if !ok {
+		return nil, errUnknownTopic
+	}
+
+	switch dir {
+	case "t/":
+		var t models.FeedToken
+		if err := s.db.First(&t, "token = ?", name).Error; err != nil || t.AccountID == nil {
+			return nil, errUnknownTopic
+		}
+		var account models.Account
+		if err := s.db.First(&account, "id = ?", *t.AccountID).Error; err != nil {
+			return nil, errUnknownTopic
+		}
+		return s.accountTopic(&account, query, format, topic)
+	case "group/":
+		return s.aggregateTopic(query, format, topic, name, token != nil, "group:"+name, name, "分组「"+name+"」的最新文章", s.groupScope(name))
+	case "":
+		if name == "all" {
+			return s.aggregateTopic(query, format, topic, "", token != nil, "all", "全部公众号", "所有公众号的最新文章", nil)
+		}
+		account, err := s.findAccount(name)
+		if err != nil {
+			return nil, errUnknownTopic
+		}
+		if account.FeedPrivate && (token == nil || (token.AccountID != nil && *token.AccountID != account.ID)) {
+			return nil, errFeedForbidden
+		}
+		return s.accountTopic(account, query, format, topic)
+	}
+	return nil, errUnknownTopic
+}
+
+func (s *Server) topicToken(value string) (*models.FeedToken, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var token models.FeedToken
+	if err := s.db.First(&token, "token = ?", value).Error; err != nil {
+		return nil, errFeedForbidden
+	}
+	return &token, nil
+}
+
+func (s *Server) accountTopic(account *models.Account, query url.Values, format feed.Format, topic string) (*topicFeed, error) {
+	opts, err := s.feedOptions(query, account.FeedOptions)
+	if err != nil {
+		return nil, err
+	}
+	return &topicFeed{
+		accountID: &account.ID,
+		format:    format,
+		build: func() (*feed.Feed, error) {
+			f, _, err := s.buildAccountFeed(account, opts, topic, 1)
+			return f, err
+		},
+	}, nil
+}
+
+func (s *Server) aggregateTopic(query url.Values, format feed.Format, topic, group string, includePrivate bool, id, title, description string, scope func(*gorm.DB) *gorm.DB) (*topicFeed, error) {
+	opts, err := s.feedOptions(query, models.FeedOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &topicFeed{
+		group:  group,
+		format: format,
+		build: func() (*feed.Feed, error) {
+			return s.buildAggregateFeed(id, title, description, topic, scope, includePrivate, opts)
+		},
+	}, nil
+}
+
+// PublishArticles announces that the feeds containing accountID's
+// articles changed: the built-in hub pushes them to its subscribers,
+// while an external hub is pinged with their URLs.
+func (s *Server) PublishArticles(accountID uint) {
+	switch s.cfg.WebSubHub {
+	case "":
+		return
+	case websubSelf:
+		go s.pushSubscribers(accountID)
+	default:
+		go s.pingHub(accountID)
+	}
+}
+
+func (s *Server) pushSubscribers(accountID uint) {
+	var account models.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		log.Printf("websub push account %d error: %v", accountID, err)
+		return
+	}
+	if err := s.db.Where("expires_at < ?", time.Now()).Delete(&models.WebSubSubscription{}).Error; err != nil {
+		log.Printf("websub expire error: %v", err)
+	}
+
+	var subs []models.WebSubSubscription
+	if err := s.db.Where("account_id = ? OR (account_id IS NULL AND group_name IN ?)",
+		accountID, []string{"", account.GroupName}).Find(&subs).Error; err != nil {
+		log.Printf("websub push account %d error: %v", accountID, err)
+		return
+	}
+
+	// subscribers of the same topic receive the same document
+	rendered := make(map[string]*feed.Rendered)
+	for i := range subs {
+		sub := &subs[i]
+		doc, ok := rendered[sub.Topic]
+		if !ok {
+			var err error
+			if doc, err = s.renderTopic(sub.Topic); err != nil {
+				s.recordDelivery(sub, err)
+				continue
+			}
+			rendered[sub.Topic] = doc
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := websub.Deliver(ctx, s.websubClient, sub.Callback, sub.Secret, s.topicHub(sub.Topic), sub.Topic, doc.ContentType, doc.Body)
+		cancel()
+		s.recordDelivery(sub, err)
+	}
+}
+
+func (s *Server) renderTopic(topic string) (*feed.Rendered, error) {
+	resolved, err := s.resolveTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+	f, err := resolved.build()
+	if err != nil {
+		return nil, err
+	}
+	f.Hub = s.topicHub(topic)
+	return feed.RenderDocument(f, resolved.format)
+}
+
+// topicHub is the built-in hub's URL on the host a topic was served from.
+func (s *Server) topicHub(topic string) string {
+	if s.cfg.PublicBaseURL != "" {
+		return s.cfg.PublicBaseURL + websubPath
+	}
+	u, err := url.Parse(topic)
+	if err != nil {
+		return ""
+	}
+	return u.Scheme + "://" + u.Host + websubPath
+}
+
+func (s *Server) recordDelivery(sub *models.WebSubSubscription, err error) {
+	if errors.Is(err, websub.ErrGone) {
+		if err := s.db.Delete(sub).Error; err != nil {
+			log.Printf("websub subscription %d delete error: %v", sub.ID, err)
+		}
+		return
+	}
+	updates := map[string]any{"last_error": ""}
+	if err != nil {
+		log.Printf("websub delivery to %s error: %v", sub.Callback, err)
+		updates["last_error"] = err.Error()
+	} else {
+		updates["last_delivered_at"] = time.Now()
+	}
+	if err := s.db.Model(sub).Updates(updates).Error; err != nil {
+		log.Printf("websub subscription %d update error: %v", sub.ID, err)
+	}
+}
+
+// pingHub notifies an external hub of the public feeds showing an
+// account. Topic URLs must be absolute, so this requires PUBLIC_BASE_URL.
+// Token URLs are never sent: they are secrets, and a third-party hub would
+// learn and fetch them. Private accounts are skipped entirely, since their
+// articles are left out of the public aggregate feeds.
+func (s *Server) pingHub(accountID uint) {
+	base := s.cfg.PublicBaseURL
+	if base == "" {
+		log.Printf("websub ping skipped: PUBLIC_BASE_URL is not set")
+		return
+	}
+	var account models.Account
+	if err := s.db.First(&account, "id = ?", accountID).Error; err != nil {
+		log.Printf("websub ping account %d error: %v", accountID, err)
+		return
+	}
+
+	if account.FeedPrivate {
+		return
+	}
+
+	paths := []string{"/feed/all", "/feed/" + strconv.Itoa(int(account.ID))}
+	if account.GroupName != "" {
+		paths = append(paths, "/feed/group/"+url.PathEscape(account.GroupName))
+	}
+
+	var topics []string
+	for _, p := range paths {
+		for _, format := range []feed.Format{feed.FormatRSS, feed.FormatAtom, feed.FormatJSON} {
+			topics = append(topics, base+p+feedExt(format))
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := websub.Ping(ctx, s.websubClient, s.cfg.WebSubHub, topics); err != nil {
+		log.Printf("websub ping %s error: %v", s.cfg.WebSubHub, err)
+	}
+}
+
+type webSubSubscriptionView struct {
+	ID              uint       `json:"id"`
+	Topic           string     `json:"topic"`
+	Callback        string     `json:"callback"`
+	Signed          bool       `json:"signed"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at"`
+	LastError       string     `json:"last_error"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (s *Server) handleListWebSubSubscriptions(c *gin.Context) {
+	var subs []models.WebSubSubscription
+	if err := s.db.Order("id desc").Find(&subs).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to list subscriptions")
+		return
+	}
+	result := make([]webSubSubscriptionView, 0, len(subs))
+	for _, sub := range subs {
+		result = append(result, webSubSubscriptionView{
+			ID:              sub.ID,
+			Topic:           sub.Topic,
+			Callback:        sub.Callback,
+			Signed:          sub.Secret != "",
+			ExpiresAt:       sub.ExpiresAt,
+			LastDeliveredAt: sub.LastDeliveredAt,
+			LastError:       sub.LastError,
+			CreatedAt:       sub.CreatedAt,
+		})
+	}
+	respondOK(c, apiData{"subscriptions": result, "hub": s.cfg.WebSubHub})
+}
+
+func (s *Server) handleDeleteWebSubSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		respondError(c, http.StatusNotFound, "subscription not found")
+		return
+	}
+	if err := s.db.Delete(&models.WebSubSubscription{}, id).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, "failed to delete subscription")
+		return
+	}
+	respondOK(c, apiData{"deleted": id})
+}
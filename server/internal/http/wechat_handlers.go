@@ -36,6 +36,7 @@ func (s *Server) handleCreateWechatSession(c *gin.Context) {
 		respondError(c, http.StatusInternalServerError, fmt.Sprintf("failed to create session: %v", err))
 		return
 	}
+	auditChange(c, session.ID, nil, apiData{"status": session.Status})
 	respondOK(c, apiData{"session": toWechatSessionView(session)})
 }
 
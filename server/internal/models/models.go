@@ -7,15 +7,41 @@ import (
 	"gorm.io/gorm"
 )
 
-// User represents admin account.
+// User represents a login of the web UI and APIs.
 type User struct {
 	ID           uint   `gorm:"primaryKey"`
 	Username     string `gorm:"uniqueIndex"`
 	PasswordHash string
+	Role         string `gorm:"default:'viewer'"` // admin, editor, viewer
+	Disabled     bool
 	ForceReset   bool
-	CreatedAt    time.Time
-	UpdatedAt    time.Time
-	DeletedAt    gorm.DeletedAt `gorm:"index"`
+	// TOTPSecret is set once enrolment starts; TOTPEnabled only after the
+	// first code was verified. TOTPCounter is the last accepted time step.
+	TOTPSecret  string
+	TOTPEnabled bool
+	TOTPCounter int64
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
+}
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+var roleRank = map[string]int{RoleViewer: 1, RoleEditor: 2, RoleAdmin: 3}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return roleRank[role] > 0
+}
+
+// HasRole reports whether the user's role includes the permissions of
+// role; admins can do everything editors can, editors what viewers can.
+func (u *User) HasRole(role string) bool {
+	return roleRank[u.Role] >= roleRank[role]
 }
 
 // SetPassword hashes and stores a password.
@@ -33,6 +59,118 @@ func (u *User) CheckPassword(password string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)) == nil
 }
 
+// UserSession is a console login session. The cookie carries a random
+// token; only its SHA-256 is stored. UserID is nil while a login waits for
+// its second factor.
+type UserSession struct {
+	ID         uint   `gorm:"primaryKey"`
+	TokenHash  string `gorm:"uniqueIndex"`
+	UserID     *uint  `gorm:"index"`
+	User       *User  `gorm:"constraint:OnDelete:CASCADE"`
+	Data       []byte
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time
+	LastSeenAt time.Time `gorm:"index"`
+	ExpiresAt  time.Time `gorm:"index"`
+}
+
+// AuditEvent records a state-changing API request: who made it, against
+// what, and the target's state before and after when the handler knows it.
+type AuditEvent struct {
+	ID         uint  `gorm:"primaryKey"`
+	ActorID    *uint `gorm:"index"`
+	ActorName  string
+	TokenID    *uint
+	Action     string  `gorm:"index"` // method and route, e.g. "PUT /api/accounts/:id"
+	TargetType string  `gorm:"index:idx_audit_target"`
+	TargetID   string  `gorm:"index:idx_audit_target"`
+	Before     *string `gorm:"type:jsonb"`
+	After      *string `gorm:"type:jsonb"`
+	Status     int
+	IP         string
+	UserAgent  string
+	CreatedAt  time.Time `gorm:"index"`
+}
+
+// RecoveryCode is a single-use fallback for a lost TOTP device, stored as
+// its SHA-256.
+type RecoveryCode struct {
+	ID        uint  `gorm:"primaryKey"`
+	UserID    uint  `gorm:"index"`
+	User      *User `gorm:"constraint:OnDelete:CASCADE"`
+	CodeHash  string
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// Setting is a runtime option changed by admins through the API.
+type Setting struct {
+	Key       string `gorm:"primaryKey"`
+	Value     string
+	UpdatedAt time.Time
+}
+
+// SettingRequireAdmin2FA makes TOTP mandatory for admins.
+const SettingRequireAdmin2FA = "require_admin_2fa"
+
+// APIToken is a personal access token. Only the SHA-256 of the token is
+// stored; Prefix keeps its first characters so users can tell tokens apart.
+type APIToken struct {
+	ID         uint  `gorm:"primaryKey"`
+	UserID     uint  `gorm:"index"`
+	User       *User `gorm:"constraint:OnDelete:CASCADE"`
+	Name       string
+	Prefix     string
+	TokenHash  string `gorm:"uniqueIndex"`
+	Scopes     string // comma-separated: read, tasks, full
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	LastUsedIP string
+	CreatedAt  time.Time
+}
+
+const (
+	ScopeRead  = "read"
+	ScopeTasks = "tasks"
+	ScopeFull  = "full"
+)
+
+// LoginLockout counts recent failed logins for one username or client IP
+// and holds the lockout they triggered.
+type LoginLockout struct {
+	ID            uint   `gorm:"primaryKey"`
+	Scope         string `gorm:"uniqueIndex:idx_login_lockout"` // user, ip
+	Key           string `gorm:"uniqueIndex:idx_login_lockout"`
+	Failures      int
+	LastFailureAt time.Time `gorm:"index"`
+	LockedUntil   *time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+const (
+	LockoutScopeUser = "user"
+	LockoutScopeIP   = "ip"
+)
+
+// LoginAttempt records a rejected login.
+type LoginAttempt struct {
+	ID        uint   `gorm:"primaryKey"`
+	Username  string `gorm:"index"`
+	IP        string `gorm:"index"`
+	UserAgent string
+	Reason    string
+	CreatedAt time.Time `gorm:"index"`
+}
+
+const (
+	LoginReasonBadCredentials = "bad_credentials"
+	LoginReasonLocked         = "locked"
+	LoginReasonDisabled       = "disabled"
+	LoginReasonBadCode        = "bad_code"
+)
+
 // WechatSession tracks an authenticated session.
 type WechatSession struct {
 	ID         uint   `gorm:"primaryKey"`
@@ -50,17 +188,75 @@ type WechatSession struct {
 
 // Account represents a tracked public account.
 type Account struct {
-	ID         uint `gorm:"primaryKey"`
-	Name       string
-	WechatID   string `gorm:"uniqueIndex"`
-	BizID      string `gorm:"index"`
-	Alias      string
-	Status     string `gorm:"default:'active'"`
-	SessionID  *uint
-	Session    *WechatSession `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
-	LastTaskID *uint
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	ID          uint `gorm:"primaryKey"`
+	Name        string
+	WechatID    string `gorm:"uniqueIndex"`
+	BizID       string `gorm:"index"`
+	Alias       string
+	GroupName   string `gorm:"index"`
+	Status      string `gorm:"default:'active'"`
+	FeedPrivate bool
+	FeedOptions FeedOptions `gorm:"embedded;embeddedPrefix:feed_"`
+	SessionID   *uint
+	Session     *WechatSession `gorm:"constraint:OnUpdate:CASCADE,OnDelete:SET NULL"`
+	LastTaskID  *uint
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// FeedOptions are the saved defaults for an account's feed; query
+// parameters override them per request. Include and Exclude hold
+// comma-separated keywords, or a /regexp/ matched against title and author.
+type FeedOptions struct {
+	Limit       int
+	SummaryOnly bool
+	Include     string
+	Exclude     string
+	FirstOnly   bool
+}
+
+// FeedToken grants read access to private feeds. Tokens without an
+// AccountID are global and open every feed.
+type FeedToken struct {
+	ID            uint     `gorm:"primaryKey"`
+	AccountID     *uint    `gorm:"index"`
+	Account       *Account `gorm:"constraint:OnDelete:CASCADE"`
+	Name          string
+	Token         string `gorm:"uniqueIndex"`
+	LastFetchedAt *time.Time
+	LastUserAgent string
+	LastIP        string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+// SavedSearch is a stored keyword query served as an aggregated feed.
+type SavedSearch struct {
+	ID        uint `gorm:"primaryKey"`
+	Name      string
+	Query     string
+	GroupName string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebSubSubscription is a verified subscriber of the built-in hub.
+// AccountID and GroupName record which articles affect the topic: an
+// account feed, a group feed, or every article when both are empty.
+type WebSubSubscription struct {
+	ID              uint   `gorm:"primaryKey"`
+	Topic           string `gorm:"uniqueIndex:idx_websub_topic_callback"`
+	Callback        string `gorm:"uniqueIndex:idx_websub_topic_callback"`
+	CallbackHost    string `gorm:"index"`
+	Secret          string
+	AccountID       *uint     `gorm:"index"`
+	Account         *Account  `gorm:"constraint:OnDelete:CASCADE"`
+	GroupName       string    `gorm:"index"`
+	ExpiresAt       time.Time `gorm:"index"`
+	LastDeliveredAt *time.Time
+	LastError       string
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
 }
 
 // Task records a crawl execution.
@@ -103,25 +299,138 @@ const (
 
 // Article stores fetched items.
 type Article struct {
-	ID              uint   `gorm:"primaryKey"`
+	ID              uint   `gorm:"primaryKey;index:idx_articles_published_id,priority:2"`
 	AccountID       uint   `gorm:"index"`
 	WechatArticleID string `gorm:"index"`
+	ItemIndex       int    `gorm:"not null;default:0"` // position within a multi-article push, 1-based; 0 when unknown
 	Title           string
+	Author          string `gorm:"index"`
 	Summary         string `gorm:"type:text"`
 	ContentHTML     string `gorm:"type:text"`
+	ContentText     string `gorm:"type:text"`
+	ContentMarkdown string `gorm:"type:text"`
+	WordCount       int
+	ImageCount      int
+	BodyHash        string
+	CoverURL        string
 	RawURL          string
-	PublishedAt     time.Time `gorm:"index"`
+	PublishedAt     time.Time `gorm:"index;index:idx_articles_published_id,priority:1"`
+	ContentStatus   string    `gorm:"index;default:'normal'"` // normal, deleted, taken_down
+	RemovedAt       *time.Time
+	CheckedAt       *time.Time `gorm:"index"`
+	SearchVector    string     `gorm:"type:tsvector;index:idx_articles_search,type:gin;->:false;<-:false"` // maintained by search.Engine
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
 }
 
-// Alert records system alerts.
-type Alert struct {
-	ID         uint   `gorm:"primaryKey"`
-	Type       string `gorm:"index"`
-	Status     string
-	Payload    string `gorm:"type:text"`
-	NotifiedAt *time.Time
+const (
+	ArticleStatusNormal    = "normal"
+	ArticleStatusDeleted   = "deleted"
+	ArticleStatusTakenDown = "taken_down"
+)
+
+// UserArticleState is a user's reading state for one article. Articles
+// without a row are unread and unstarred.
+type UserArticleState struct {
+	ID         uint     `gorm:"primaryKey"`
+	UserID     uint     `gorm:"uniqueIndex:idx_user_article"`
+	ArticleID  uint     `gorm:"uniqueIndex:idx_user_article;index"`
+	Article    *Article `gorm:"constraint:OnDelete:CASCADE"`
+	Read       bool
+	Starred    bool
+	Archived   bool
+	ReadAt     *time.Time
+	StarredAt  *time.Time
+	ArchivedAt *time.Time
+	Note       string `gorm:"type:text"`
 	CreatedAt  time.Time
 	UpdatedAt  time.Time
 }
+
+// UserArticleTag is a free-form label a user attached to an article.
+type UserArticleTag struct {
+	ID        uint     `gorm:"primaryKey"`
+	UserID    uint     `gorm:"uniqueIndex:idx_user_article_tag;index:idx_user_tag"`
+	ArticleID uint     `gorm:"uniqueIndex:idx_user_article_tag;index"`
+	Article   *Article `gorm:"constraint:OnDelete:CASCADE"`
+	Tag       string   `gorm:"uniqueIndex:idx_user_article_tag;index:idx_user_tag"`
+	CreatedAt time.Time
+}
+
+// ArticleRevision keeps each distinct version of an article body.
+type ArticleRevision struct {
+	ID          uint `gorm:"primaryKey"`
+	ArticleID   uint `gorm:"uniqueIndex:idx_article_revision"`
+	Revision    int  `gorm:"uniqueIndex:idx_article_revision"`
+	Title       string
+	Author      string
+	Summary     string `gorm:"type:text"`
+	ContentHTML string `gorm:"type:text"`
+	BodyHash    string
+	CreatedAt   time.Time
+}
+
+// Alert is a problem raised by the alert engine. Key identifies the
+// condition, such as one account's failing tasks; at most one unresolved
+// alert exists per key. Payload holds JSON details.
+type Alert struct {
+	ID             uint   `gorm:"primaryKey"`
+	Type           string `gorm:"index"`
+	Key            string `gorm:"uniqueIndex:idx_alert_unresolved,where:status <> 'resolved'"`
+	Status         string `gorm:"index;default:'open'"` // open, acknowledged, resolved
+	Severity       string // warning, critical
+	Message        string
+	AccountID      *uint  `gorm:"index"`
+	SessionID      *uint  `gorm:"index"`
+	Payload        string `gorm:"type:text"`
+	LastSeenAt     time.Time
+	AcknowledgedAt *time.Time
+	AcknowledgedBy *uint
+	ResolvedAt     *time.Time
+	NotifiedAt     *time.Time
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+	AlertStatusResolved     = "resolved"
+
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+
+	AlertSessionExpired = "session_expired"
+	AlertTaskFailures   = "task_failures"
+	AlertStaleAccount   = "stale_account"
+	AlertFreqControl    = "freq_control"
+	AlertCrawlerStall   = "crawler_stall"
+)
+
+// AlertChannel delivers alert notifications to a webhook, a chat robot or
+// email recipients. Types limits it to the listed alert types (comma
+// separated, empty for all) and MinSeverity to critical alerts when set.
+type AlertChannel struct {
+	ID             uint `gorm:"primaryKey"`
+	Name           string
+	Kind           string // webhook, email, wecom, feishu, dingtalk, slack
+	Target         string // URL, or comma-separated addresses for email
+	Secret         string // webhook HMAC key or robot signing secret
+	Types          string
+	MinSeverity    string
+	NotifyResolved bool
+	Enabled        bool
+	LastSentAt     *time.Time
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+const (
+	AlertChannelWebhook  = "webhook"
+	AlertChannelEmail    = "email"
+	AlertChannelWeCom    = "wecom"
+	AlertChannelFeishu   = "feishu"
+	AlertChannelDingTalk = "dingtalk"
+	AlertChannelSlack    = "slack"
+)
@@ -0,0 +1,181 @@
+package search
+
+import (
+	"context"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/content"
+	"wechat2rss/internal/models"
+)
+
+// ngramConfig is the built-in text search configuration used with the
+// bigram fallback; it does no stemming or stop-word removal.
+const ngramConfig = "simple"
+
+// Engine maintains articles.search_vector and builds full-text queries.
+//
+// When a PostgreSQL text search configuration with Chinese support (for
+// example one created with zhparser) is configured and present, documents
+// and queries are parsed by PostgreSQL. Otherwise CJK text is split into
+// overlapping bigrams in Go and indexed with the "simple" configuration.
+type Engine struct {
+	db       *gorm.DB
+	tsConfig string
+}
+
+// New returns an engine using tsConfig if the database knows it, falling
+// back to bigram tokenisation otherwise.
+func New(db *gorm.DB, tsConfig string) *Engine {
+	e := &Engine{db: db}
+	if tsConfig == "" {
+		return e
+	}
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM pg_ts_config WHERE cfgname = ?", tsConfig).Scan(&count).Error; err != nil || count == 0 {
+		log.Printf("search: text search config %q unavailable, using bigram fallback", tsConfig)
+		return e
+	}
+	e.tsConfig = tsConfig
+	return e
+}
+
+// Index recomputes the search vector for article from its title, summary
+// and the plain text of its body.
+func (e *Engine) Index(article *models.Article) error {
+	body := article.ContentText
+	if body == "" && article.ContentHTML != "" {
+		body = content.PlainText(article.ContentHTML)
+	}
+	title, summary := article.Title, article.Summary
+	cfg := e.tsConfig
+	if cfg == "" {
+		cfg = ngramConfig
+		title, summary, body = Tokens(title), Tokens(summary), Tokens(body)
+	}
+	return e.db.Exec(`UPDATE articles SET search_vector =
+		setweight(to_tsvector(?::regconfig, ?), 'A') ||
+		setweight(to_tsvector(?::regconfig, ?), 'B') ||
+		setweight(to_tsvector(?::regconfig, ?), 'C')
+		WHERE id = ?`,
+		cfg, title, cfg, summary, cfg, body, article.ID).Error
+}
+
+// IndexMissing indexes articles that have no search vector yet, such as
+// rows archived before search existed.
+func (e *Engine) IndexMissing(ctx context.Context) {
+	for {
+		var articles []models.Article
+		if err := e.db.Where("search_vector IS NULL").Order("id").Limit(100).Find(&articles).Error; err != nil {
+			log.Printf("search: load unindexed articles: %v", err)
+			return
+		}
+		if len(articles) == 0 {
+			return
+		}
+		for i := range articles {
+			if err := e.Index(&articles[i]); err != nil {
+				log.Printf("search: index article %d: %v", articles[i].ID, err)
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Match narrows query to articles matching the user query q.
+func (e *Engine) Match(query *gorm.DB, q string) *gorm.DB {
+	if e.tsConfig != "" {
+		return query.Where("search_vector @@ websearch_to_tsquery(?::regconfig, ?)", e.tsConfig, q)
+	}
+	tsquery := ngramQuery(q)
+	if tsquery == "" {
+		return query.Where("1 = 0")
+	}
+	return query.Where("search_vector @@ to_tsquery(?::regconfig, ?)", ngramConfig, tsquery)
+}
+
+// Tokens converts text to the whitespace separated lexemes indexed by the
+// bigram fallback: lower-cased words for alphabetic scripts and
+// overlapping bigrams for runs of CJK characters.
+func Tokens(text string) string {
+	return strings.Join(tokenize(text), " ")
+}
+
+func tokenize(text string) []string {
+	var (
+		tokens []string
+		word   []rune
+		cjk    []rune
+	)
+	flushWord := func() {
+		if len(word) > 0 {
+			tokens = append(tokens, strings.ToLower(string(word)))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			tokens = append(tokens, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				tokens = append(tokens, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+	for _, r := range text {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return tokens
+}
+
+// ngramQuery turns each whitespace separated term of q into a phrase of
+// adjacent bigrams and requires all terms to match.
+func ngramQuery(q string) string {
+	var terms []string
+	for _, field := range strings.Fields(q) {
+		tokens := tokenize(field)
+		if len(tokens) == 0 {
+			continue
+		}
+		quoted := make([]string, 0, len(tokens))
+		for _, token := range tokens {
+			quoted = append(quoted, "'"+strings.ReplaceAll(token, "'", "''")+"'")
+		}
+		if len(tokens) == 1 {
+			// a single character or word also matches longer lexemes
+			quoted[0] += ":*"
+		}
+		terms = append(terms, "("+strings.Join(quoted, " <-> ")+")")
+	}
+	return strings.Join(terms, " & ")
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) ||
+		unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) ||
+		unicode.Is(unicode.Hangul, r)
+}
@@ -0,0 +1,73 @@
+package search
+
+import (
+	"html"
+	"strings"
+)
+
+// snippetRadius is the number of runes kept on each side of the first hit.
+const snippetRadius = 60
+
+// Snippet returns an HTML-escaped excerpt of text around the first
+// occurrence of any term in q, with every occurrence wrapped in <mark>.
+func Snippet(text, q string) string {
+	terms := strings.Fields(strings.ToLower(q))
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+	if len(lower) != len(runes) {
+		// case folding changed the length; match on the original text
+		lower = runes
+	}
+
+	first := -1
+	for _, term := range terms {
+		if idx := runeIndex(lower, []rune(term)); idx >= 0 && (first < 0 || idx < first) {
+			first = idx
+		}
+	}
+
+	start, end := 0, len(runes)
+	if first >= 0 {
+		start = max(0, first-snippetRadius)
+		end = min(len(runes), first+snippetRadius)
+	} else if end > 2*snippetRadius {
+		end = 2 * snippetRadius
+	}
+
+	var sb strings.Builder
+	if start > 0 {
+		sb.WriteString("…")
+	}
+	for i := start; i < end; {
+		matched := 0
+		for _, term := range terms {
+			tr := []rune(term)
+			if len(tr) > matched && i+len(tr) <= len(lower) && string(lower[i:i+len(tr)]) == term {
+				matched = len(tr)
+			}
+		}
+		if matched > 0 {
+			sb.WriteString("<mark>" + html.EscapeString(string(runes[i:i+matched])) + "</mark>")
+			i += matched
+			continue
+		}
+		sb.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		sb.WriteString("…")
+	}
+	return sb.String()
+}
+
+func runeIndex(haystack, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		if string(haystack[i:i+len(needle)]) == string(needle) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+)
+
+// PruneAuditEvents deletes audit events older than retentionDays once a
+// day until ctx is done. A retention of zero keeps events forever.
+func PruneAuditEvents(ctx context.Context, db *gorm.DB, retentionDays int) {
+	if retentionDays <= 0 {
+		return
+	}
+	prune := func() {
+		cutoff := time.Now().AddDate(0, 0, -retentionDays)
+		result := db.Where("created_at < ?", cutoff).Delete(&models.AuditEvent{})
+		if result.Error != nil {
+			log.Printf("audit prune error: %v", result.Error)
+		} else if result.RowsAffected > 0 {
+			log.Printf("audit prune removed %d events older than %d days", result.RowsAffected, retentionDays)
+		}
+	}
+
+	prune()
+	ticker := time.NewTicker(24 * time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			prune()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"wechat2rss/internal/models"
+)
+
+// Setting returns the stored value of key, or "" when it was never set.
+func Setting(db *gorm.DB, key string) (string, error) {
+	var setting models.Setting
+	err := db.First(&setting, "key = ?", key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	return setting.Value, err
+}
+
+// SettingBool reads key as a boolean, false when unset or malformed.
+func SettingBool(db *gorm.DB, key string) (bool, error) {
+	value, err := Setting(db, key)
+	if err != nil || value == "" {
+		return false, err
+	}
+	b, _ := strconv.ParseBool(value)
+	return b, nil
+}
+
+// SetSetting stores value under key.
+func SetSetting(db *gorm.DB, key, value string) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+	}).Create(&models.Setting{Key: key, Value: value}).Error
+}
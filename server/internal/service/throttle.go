@@ -0,0 +1,133 @@
+package service
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+)
+
+const (
+	baseLoginDelay = 250 * time.Millisecond
+	maxLoginDelay  = 8 * time.Second
+	// attemptRetention is how long rejected logins are kept.
+	attemptRetention = 30 * 24 * time.Hour
+)
+
+// LoginThrottle slows down and locks out repeated failed logins, counted
+// separately per username and per client IP within a sliding window.
+type LoginThrottle struct {
+	db          *gorm.DB
+	maxUser     int
+	maxIP       int
+	window      time.Duration
+	lockout     time.Duration
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// NewLoginThrottle locks a username after maxUser failures and an IP
+// after maxIP failures within window, for the lockout duration.
+func NewLoginThrottle(db *gorm.DB, maxUser, maxIP int, window, lockout time.Duration) *LoginThrottle {
+	return &LoginThrottle{db: db, maxUser: maxUser, maxIP: maxIP, window: window, lockout: lockout}
+}
+
+// Locked returns how long username or ip remain locked out, or zero.
+func (t *LoginThrottle) Locked(username, ip string) (time.Duration, error) {
+	var lockouts []models.LoginLockout
+	if err := t.db.Where("locked_until > ? AND ((scope = ? AND key = ?) OR (scope = ? AND key = ?))",
+		time.Now(), models.LockoutScopeUser, username, models.LockoutScopeIP, ip).
+		Find(&lockouts).Error; err != nil {
+		return 0, err
+	}
+	var wait time.Duration
+	for _, lockout := range lockouts {
+		wait = max(wait, time.Until(*lockout.LockedUntil))
+	}
+	return wait, nil
+}
+
+// Fail records a rejected login and returns how long to delay the
+// response, growing with the number of recent failures.
+func (t *LoginThrottle) Fail(username, ip, userAgent, reason string) time.Duration {
+	if err := t.db.Create(&models.LoginAttempt{
+		Username:  username,
+		IP:        ip,
+		UserAgent: userAgent,
+		Reason:    reason,
+	}).Error; err != nil {
+		log.Printf("login attempt record error: %v", err)
+	}
+	if reason != models.LoginReasonBadCredentials && reason != models.LoginReasonBadCode {
+		return 0
+	}
+
+	userFailures := t.count(models.LockoutScopeUser, username, t.maxUser)
+	ipFailures := t.count(models.LockoutScopeIP, ip, t.maxIP)
+	t.cleanup()
+
+	failures := max(userFailures, ipFailures)
+	if failures <= 1 {
+		return baseLoginDelay
+	}
+	return min(baseLoginDelay<<(failures-1), maxLoginDelay)
+}
+
+// count increments the failure counter of key, restarting it when the
+// previous failure fell outside the window, and locks key once limit is
+// reached.
+func (t *LoginThrottle) count(scope, key string, limit int) int {
+	now := time.Now()
+	var failures int
+	if err := t.db.Raw(`INSERT INTO login_lockouts (scope, key, failures, last_failure_at, created_at, updated_at)
+		VALUES (?, ?, 1, ?, ?, ?)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures = CASE WHEN login_lockouts.last_failure_at < ? THEN 1 ELSE login_lockouts.failures + 1 END,
+			last_failure_at = EXCLUDED.last_failure_at,
+			updated_at = EXCLUDED.updated_at
+		RETURNING failures`,
+		scope, key, now, now, now, now.Add(-t.window)).Scan(&failures).Error; err != nil {
+		log.Printf("login throttle %s %s error: %v", scope, key, err)
+		return 0
+	}
+	if limit > 0 && failures >= limit {
+		if err := t.db.Model(&models.LoginLockout{}).
+			Where("scope = ? AND key = ?", scope, key).
+			Update("locked_until", now.Add(t.lockout)).Error; err != nil {
+			log.Printf("login lockout %s %s error: %v", scope, key, err)
+		}
+	}
+	return failures
+}
+
+// Succeed clears the username counter of a successful login. The IP
+// counter is left to expire with its window: otherwise anyone holding a
+// valid account could log in between guesses to reset the IP budget.
+func (t *LoginThrottle) Succeed(username string) {
+	if err := t.db.Where("scope = ? AND key = ?", models.LockoutScopeUser, username).
+		Delete(&models.LoginLockout{}).Error; err != nil {
+		log.Printf("login throttle reset error: %v", err)
+	}
+}
+
+// cleanup drops expired counters and old attempts at most once a minute.
+func (t *LoginThrottle) cleanup() {
+	now := time.Now()
+	t.mu.Lock()
+	if now.Sub(t.lastCleanup) < time.Minute {
+		t.mu.Unlock()
+		return
+	}
+	t.lastCleanup = now
+	t.mu.Unlock()
+	if err := t.db.Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", now.Add(-t.window), now).
+		Delete(&models.LoginLockout{}).Error; err != nil {
+		log.Printf("login lockout cleanup error: %v", err)
+	}
+	if err := t.db.Where("created_at < ?", now.Add(-attemptRetention)).Delete(&models.LoginAttempt{}).Error; err != nil {
+		log.Printf("login attempt cleanup error: %v", err)
+	}
+}
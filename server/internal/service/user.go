@@ -9,12 +9,15 @@ import (
 )
 
 // EnsureAdmin ensures an admin user exists with provided credentials.
+// When no enabled admin is left, for instance after upgrading from the
+// single-user schema, the configured user is promoted again.
 func EnsureAdmin(db *gorm.DB, username, password string) error {
 	var existing models.User
 	err := db.First(&existing, "username = ?", username).Error
 	if errors.Is(err, gorm.ErrRecordNotFound) {
 		user := models.User{
 			Username:   username,
+			Role:       models.RoleAdmin,
 			ForceReset: true,
 		}
 		if err := user.SetPassword(password); err != nil {
@@ -25,5 +28,34 @@ func EnsureAdmin(db *gorm.DB, username, password string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+
+	admins, err := CountAdmins(db)
+	if err != nil || admins > 0 {
+		return err
+	}
+	return db.Model(&existing).Updates(map[string]any{"role": models.RoleAdmin, "disabled": false}).Error
+}
+
+// CountAdmins returns the number of enabled admins.
+func CountAdmins(db *gorm.DB) (int64, error) {
+	var count int64
+	err := db.Model(&models.User{}).
+		Where("role = ? AND disabled = ?", models.RoleAdmin, false).
+		Count(&count).Error
+	return count, err
+}
+
+// DeleteUser removes a user for good, together with their reading state.
+// Sessions, recovery codes and API tokens go with the row through their
+// foreign keys, and the username becomes free again.
+func DeleteUser(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserArticleState{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.UserArticleTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&models.User{}, userID).Error
+	})
 }
@@ -0,0 +1,254 @@
+// Package sessionstore keeps console sessions in the database so they can
+// be listed and revoked. It implements the gin-contrib/sessions Store.
+package sessionstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-contrib/sessions"
+	gsessions "github.com/gorilla/sessions"
+	"gorm.io/gorm"
+
+	"wechat2rss/internal/models"
+)
+
+const (
+	// UserKey is the session value holding the logged-in user ID.
+	UserKey = "user_id"
+	// touchInterval limits last-seen bookkeeping to one write per session
+	// and interval.
+	touchInterval   = time.Minute
+	cleanupInterval = 10 * time.Minute
+)
+
+type clientIPKey struct{}
+
+// WithClientIP attaches the client address, as resolved behind trusted
+// proxies, to be recorded with the session.
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return r.RemoteAddr
+}
+
+// Store loads and saves sessions in the user_sessions table. A session
+// ends after idle without requests, and at the latest absolute after it
+// was created.
+type Store struct {
+	db       *gorm.DB
+	options  *gsessions.Options
+	idle     time.Duration
+	absolute time.Duration
+
+	mu          sync.Mutex
+	lastCleanup time.Time
+}
+
+// New returns a store using options for its cookies. An idle of zero
+// disables the idle timeout.
+func New(db *gorm.DB, idle, absolute time.Duration, options sessions.Options) *Store {
+	return &Store{db: db, options: options.ToGorillaOptions(), idle: idle, absolute: absolute}
+}
+
+// Options sets the cookie options of new sessions.
+func (s *Store) Options(options sessions.Options) {
+	s.options = options.ToGorillaOptions()
+}
+
+// Get returns the session of name, cached for the request.
+func (s *Store) Get(r *http.Request, name string) (*gsessions.Session, error) {
+	return gsessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request cookie, or returns an empty
+// one when there is none or it has ended.
+func (s *Store) New(r *http.Request, name string) (*gsessions.Session, error) {
+	session := gsessions.NewSession(s, name)
+	opts := *s.options
+	session.Options = &opts
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil || cookie.Value == "" {
+		return session, nil
+	}
+	row, err := s.load(cookie.Value)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return session, nil
+		}
+		return session, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(row.Data)).Decode(&session.Values); err != nil {
+		log.Printf("session %d decode error: %v", row.ID, err)
+		return session, nil
+	}
+	session.ID = cookie.Value
+	session.IsNew = false
+
+	if ip := clientIP(r); time.Since(row.LastSeenAt) > touchInterval || row.IP != ip {
+		if err := s.db.Model(row).Updates(map[string]any{
+			"last_seen_at": time.Now(),
+			"ip":           ip,
+			"user_agent":   r.UserAgent(),
+		}).Error; err != nil {
+			log.Printf("session %d touch error: %v", row.ID, err)
+		}
+	}
+	return session, nil
+}
+
+// Save writes the session and its cookie. A cleared session is deleted,
+// and a session whose user changed, such as at login, gets a new token so
+// a token planted before login is worthless.
+func (s *Store) Save(r *http.Request, w http.ResponseWriter, session *gsessions.Session) error {
+	defer s.cleanup()
+
+	if session.Options.MaxAge < 0 || len(session.Values) == 0 {
+		if session.ID != "" {
+			if err := s.db.Where("token_hash = ?", HashToken(session.ID)).Delete(&models.UserSession{}).Error; err != nil {
+				return err
+			}
+		}
+		expired := *session.Options
+		expired.MaxAge = -1
+		http.SetCookie(w, gsessions.NewCookie(session.Name(), "", &expired))
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(session.Values); err != nil {
+		return err
+	}
+	var userID *uint
+	if id, ok := session.Values[UserKey].(uint); ok {
+		userID = &id
+	}
+
+	if session.ID != "" {
+		row, err := s.load(session.ID)
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			session.ID = ""
+		case err != nil:
+			return err
+		case !sameUser(row.UserID, userID):
+			if err := s.db.Delete(row).Error; err != nil {
+				return err
+			}
+			session.ID = ""
+		default:
+			if err := s.db.Model(row).Updates(map[string]any{
+				"data":         buf.Bytes(),
+				"last_seen_at": time.Now(),
+			}).Error; err != nil {
+				return err
+			}
+		}
+	}
+
+	if session.ID == "" {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		if err := s.db.Create(&models.UserSession{
+			TokenHash:  HashToken(token),
+			UserID:     userID,
+			Data:       buf.Bytes(),
+			IP:         clientIP(r),
+			UserAgent:  r.UserAgent(),
+			LastSeenAt: now,
+			ExpiresAt:  now.Add(s.absolute),
+		}).Error; err != nil {
+			return err
+		}
+		session.ID = token
+	}
+
+	opts := *session.Options
+	opts.MaxAge = int(s.absolute.Seconds())
+	http.SetCookie(w, gsessions.NewCookie(session.Name(), session.ID, &opts))
+	return nil
+}
+
+// load finds the live session of token.
+func (s *Store) load(token string) (*models.UserSession, error) {
+	query := s.db.Where("token_hash = ? AND expires_at > ?", HashToken(token), time.Now())
+	if s.idle > 0 {
+		query = query.Where("last_seen_at > ?", time.Now().Add(-s.idle))
+	}
+	var row models.UserSession
+	if err := query.First(&row).Error; err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
+
+// cleanup drops ended sessions at most once per cleanupInterval.
+func (s *Store) cleanup() {
+	now := time.Now()
+	s.mu.Lock()
+	if now.Sub(s.lastCleanup) < cleanupInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCleanup = now
+	s.mu.Unlock()
+
+	query := s.db.Where("expires_at < ?", now)
+	if s.idle > 0 {
+		query = query.Or("last_seen_at < ?", now.Add(-s.idle))
+	}
+	if err := query.Delete(&models.UserSession{}).Error; err != nil {
+		log.Printf("session cleanup error: %v", err)
+	}
+}
+
+// Active restricts query to sessions that have not ended.
+func (s *Store) Active(query *gorm.DB) *gorm.DB {
+	now := time.Now()
+	query = query.Where("expires_at > ?", now)
+	if s.idle > 0 {
+		query = query.Where("last_seen_at > ?", now.Add(-s.idle))
+	}
+	return query
+}
+
+// HashToken returns the stored form of a session token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func sameUser(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
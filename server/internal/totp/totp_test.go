@@ -0,0 +1,112 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of RFC 6238 appendix B, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// The RFC lists eight-digit codes; six-digit codes are their last six
+// digits.
+var rfcVectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestCodeRFC6238(t *testing.T) {
+	for _, v := range rfcVectors {
+		got, err := Code(rfcSecret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+	// secrets are accepted lower case and with padding
+	if got, _ := Code(strings.ToLower(rfcSecret)+"====", time.Unix(59, 0)); got != "287082" {
+		t.Errorf("lower-case padded secret gave %s", got)
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / period
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := Code(rfcSecret, now.Add(time.Duration(offset*period)*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter, ok := Validate(rfcSecret, code, now, 0)
+		if want := offset >= -skew && offset <= skew; ok != want {
+			t.Errorf("step offset %d accepted = %t, want %t", offset, ok, want)
+		}
+		if ok && counter != step+offset {
+			t.Errorf("step offset %d matched counter %d, want %d", offset, counter, step+offset)
+		}
+	}
+
+	if _, ok := Validate(rfcSecret, "005 924", now, 0); !ok {
+		t.Error("code with a space rejected")
+	}
+	for _, input := range []string{"", "00592", "0059240", "000000"} {
+		if _, ok := Validate(rfcSecret, input, now, 0); ok {
+			t.Errorf("Validate accepted %q", input)
+		}
+	}
+	if _, ok := Validate("not base32!", "005924", now, 0); ok {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidateReplay(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	counter, ok := Validate(rfcSecret, "005924", now, 0)
+	if !ok {
+		t.Fatal("current code rejected")
+	}
+	if _, ok := Validate(rfcSecret, "005924", now, counter); ok {
+		t.Error("same code accepted twice")
+	}
+	// a code from the previous step is older than the one just used
+	previous, _ := Code(rfcSecret, now.Add(-period*time.Second))
+	if _, ok := Validate(rfcSecret, previous, now, counter); ok {
+		t.Error("older code accepted after a newer one")
+	}
+	next, _ := Code(rfcSecret, now.Add(period*time.Second))
+	if got, ok := Validate(rfcSecret, next, now, counter); !ok || got != counter+1 {
+		t.Errorf("next code: counter %d ok %t", got, ok)
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	a, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateSecret()
+	if len(a) != 32 || a == b {
+		t.Errorf("secrets %q and %q", a, b)
+	}
+	if _, err := Code(a, time.Now()); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Wechat2RSS", "alice@example.com", rfcSecret)
+	want := "otpauth://totp/Wechat2RSS:alice@example.com?algorithm=SHA1&digits=6&issuer=Wechat2RSS&period=30&secret=" + rfcSecret
+	if got != want {
+		t.Errorf("URI = %s\nwant  %s", got, want)
+	}
+}
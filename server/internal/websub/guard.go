@@ -0,0 +1,62 @@
+package websub
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress reports a callback that resolves to an address the
+// hub must not contact, such as loopback or a private network.
+var ErrPrivateAddress = errors.New("callback address is not public")
+
+// publicAddr reports whether ip is a globally routable unicast address.
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsValid() && ip.IsGlobalUnicast() && !ip.IsPrivate() &&
+		!ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() &&
+		// 100.64.0.0/10 carrier-grade NAT is not covered by IsPrivate
+		!netip.MustParsePrefix("100.64.0.0/10").Contains(ip)
+}
+
+// CheckCallback resolves the host of a callback URL and rejects it when
+// any of its addresses is not public. It gives subscribers an early
+// answer; GuardedClient enforces the same rule on every connection.
+func CheckCallback(ctx context.Context, host string) error {
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrPrivateAddress
+		}
+	}
+	return nil
+}
+
+// GuardedClient returns an HTTP client for subscriber callbacks. The
+// address is checked when each connection is made, after DNS resolution
+// and for every redirect, so a host that later resolves to an internal
+// address (DNS rebinding) is still refused. Proxies are not used since
+// they would hide the final address.
+func GuardedClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 10 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addrPort.Addr()) {
+				return ErrPrivateAddress
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
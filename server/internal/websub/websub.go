@@ -0,0 +1,134 @@
+// Package websub implements the publisher and hub sides of the WebSub
+// (W3C PubSubHubbub) protocol: verifying subscriber intent, delivering
+// signed content and pinging external hubs.
+package websub
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrGone reports a subscriber that answered 410 and should be dropped.
+var ErrGone = errors.New("subscriber gone")
+
+// VerifyIntent asks callback to confirm a subscribe or unsubscribe request
+// by echoing a random challenge.
+func VerifyIntent(ctx context.Context, client *http.Client, callback, mode, topic string, leaseSeconds int) error {
+	challenge, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	u, err := url.Parse(callback)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("hub.mode", mode)
+	q.Set("hub.topic", topic)
+	q.Set("hub.challenge", challenge)
+	if mode == "subscribe" {
+		q.Set("hub.lease_seconds", strconv.Itoa(leaseSeconds))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("verification returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(body)) != challenge {
+		return errors.New("verification challenge mismatch")
+	}
+	return nil
+}
+
+// Deliver posts the current feed document to a subscriber. When secret is
+// set the body is signed with HMAC-SHA256 in X-Hub-Signature.
+func Deliver(ctx context.Context, client *http.Client, callback, secret, hub, topic, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Link", LinkHeader(hub, topic))
+	if secret != "" {
+		req.Header.Set("X-Hub-Signature", "sha256="+Sign(secret, body))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode == http.StatusGone {
+		return ErrGone
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("delivery returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Ping tells an external hub that topics have new content.
+func Ping(ctx context.Context, client *http.Client, hub string, topics []string) error {
+	form := url.Values{"hub.mode": {"publish"}}
+	for _, topic := range topics {
+		form.Add("hub.url", topic)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hub, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("hub ping returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Sign returns the hex HMAC-SHA256 of body keyed by secret.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LinkHeader advertises the hub and the topic's canonical URL.
+func LinkHeader(hub, topic string) string {
+	return fmt.Sprintf(`<%s>; rel="hub", <%s>; rel="self"`, hub, topic)
+}
+
+func randomHex(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -3,6 +3,7 @@ package wechat
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -17,6 +18,31 @@ type Credentials struct {
 	Token  string
 }
 
+// Ret codes of the mp backend's base_resp.
+const (
+	// RetSessionExpired means the login cookie and token are no longer valid.
+	RetSessionExpired = 200003
+	// RetFreqControl means the login is rate-limited ("freq control").
+	RetFreqControl = 200013
+)
+
+// APIError is a non-zero base_resp.ret from the mp backend.
+type APIError struct {
+	Op     string
+	Ret    int
+	ErrMsg string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s ret %d err %s", e.Op, e.Ret, e.ErrMsg)
+}
+
+// IsRet reports whether err is an APIError with the given ret code.
+func IsRet(err error, ret int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Ret == ret
+}
+
 var httpClient = &http.Client{
 	Timeout: 20 * time.Second,
 }
@@ -50,7 +76,7 @@ func SearchAccounts(ctx context.Context, cred Credentials, query string, begin i
 	params.Set("begin", strconv.Itoa(begin))
 	params.Set("count", "5")
 	params.Set("query", query)
-	params.Set("random", fmt.Sprintf("%f", time.Now().UTC().UnixNano()))
+	params.Set("random", fmt.Sprintf("%d", time.Now().UTC().UnixNano()))
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://mp.weixin.qq.com/cgi-bin/searchbiz?"+params.Encode(), nil)
 	if err != nil {
@@ -77,7 +103,7 @@ func SearchAccounts(ctx context.Context, cred Credentials, query string, begin i
 		return nil, err
 	}
 	if parsed.BaseResp.Ret != 0 {
-		return nil, fmt.Errorf("searchbiz ret %d err %s", parsed.BaseResp.Ret, parsed.BaseResp.ErrMsg)
+		return nil, &APIError{Op: "searchbiz", Ret: parsed.BaseResp.Ret, ErrMsg: parsed.BaseResp.ErrMsg}
 	}
 
 	return parsed.List, nil
@@ -87,6 +113,7 @@ func SearchAccounts(ctx context.Context, cred Credentials, query string, begin i
 type ArticleItem struct {
 	Aid        string `json:"aid"`
 	AppMsgID   string `json:"appmsgid"`
+	ItemIdx    int    `json:"itemidx"`
 	Title      string `json:"title"`
 	Author     string `json:"author"`
 	Digest     string `json:"digest"`
@@ -112,7 +139,7 @@ func FetchArticles(ctx context.Context, cred Credentials, fakeid string, offset
 	params.Set("lang", "zh_CN")
 	params.Set("f", "json")
 	params.Set("ajax", "1")
-	params.Set("random", fmt.Sprintf("%f", time.Now().UnixNano()))
+	params.Set("random", fmt.Sprintf("%d", time.Now().UnixNano()))
 	params.Set("begin", strconv.Itoa(offset))
 	params.Set("count", strconv.Itoa(count))
 	params.Set("type", "9")
@@ -143,7 +170,7 @@ func FetchArticles(ctx context.Context, cred Credentials, fakeid string, offset
 		return nil, err
 	}
 	if parsed.BaseResp.Ret != 0 {
-		return nil, fmt.Errorf("appmsg ret %d err %s", parsed.BaseResp.Ret, parsed.BaseResp.ErrMsg)
+		return nil, &APIError{Op: "appmsg", Ret: parsed.BaseResp.Ret, ErrMsg: parsed.BaseResp.ErrMsg}
 	}
 	return &parsed, nil
 }
@@ -1,4 +1,4 @@
-import axios from 'axios';
+import axios, { type AxiosRequestConfig } from 'axios';
 
 const http = axios.create({
   baseURL: import.meta.env.VITE_API_BASE_URL ?? '/',
@@ -6,9 +6,49 @@ const http = axios.create({
   timeout: 15000,
 });
 
+const CSRF_HEADER = 'X-CSRF-Token';
+const SAFE_METHODS = ['get', 'head', 'options'];
+
+let csrfToken: Promise<string> | null = null;
+
+// fetchCsrfToken loads the token bound to the session cookie once and
+// shares it between concurrent requests.
+const fetchCsrfToken = () => {
+  if (!csrfToken) {
+    csrfToken = http
+      .get<{ success: boolean; data: { token: string } }>('/api/csrf')
+      .then((res) => res.data.data.token)
+      .catch((err) => {
+        csrfToken = null;
+        throw err;
+      });
+  }
+  return csrfToken;
+};
+
+http.interceptors.request.use(async (config) => {
+  if (!SAFE_METHODS.includes((config.method ?? 'get').toLowerCase())) {
+    config.headers.set(CSRF_HEADER, await fetchCsrfToken());
+  }
+  return config;
+});
+
 http.interceptors.response.use(
   (response) => response,
-  (error) => {
+  async (error) => {
+    const config = error.config as (AxiosRequestConfig & { _csrfRetried?: boolean }) | undefined;
+    // the session, and its token, changes on logout or expiry: fetch a
+    // fresh token and retry once
+    if (
+      config &&
+      !config._csrfRetried &&
+      error.response?.status === 403 &&
+      error.response?.data?.error === 'invalid csrf token'
+    ) {
+      config._csrfRetried = true;
+      csrfToken = null;
+      return http(config);
+    }
     const message =
       error.response?.data?.error ||
       error.response?.data?.message ||
@@ -4,7 +4,10 @@ import http from '@/services/http';
 interface UserPayload {
   id: number;
   username: string;
+  role?: 'admin' | 'editor' | 'viewer';
   require_reset: boolean;
+  two_factor_enabled?: boolean;
+  require_two_factor?: boolean;
 }
 
 interface LoginRequest {
@@ -12,6 +15,10 @@ interface LoginRequest {
   password: string;
 }
 
+interface TwoFactorPending {
+  two_factor_required: true;
+}
+
 interface ApiResponse<T> {
   success: boolean;
   data: T;
@@ -22,6 +29,7 @@ export const useAuthStore = defineStore('auth', {
   state: () => ({
     user: null as UserPayload | null,
     initialized: false,
+    twoFactorPending: false,
   }),
   getters: {
     isAuthenticated: (state) => !!state.user && state.user.require_reset === false,
@@ -29,13 +37,27 @@ export const useAuthStore = defineStore('auth', {
   },
   actions: {
     async login(payload: LoginRequest) {
-      const res = await http.post<ApiResponse<UserPayload>>('/api/login', payload);
+      const res = await http.post<ApiResponse<UserPayload | TwoFactorPending>>('/api/login', payload);
       if (res.data.success) {
+        if ('two_factor_required' in res.data.data) {
+          this.twoFactorPending = true;
+          return null;
+        }
         this.user = res.data.data;
         return res.data.data;
       }
       throw new Error(res.data.error || '登录失败');
     },
+    async verifyTwoFactor(code: string, recovery = false) {
+      const body = recovery ? { recovery_code: code } : { code };
+      const res = await http.post<ApiResponse<UserPayload>>('/api/login/2fa', body);
+      if (res.data.success) {
+        this.twoFactorPending = false;
+        this.user = res.data.data;
+        return res.data.data;
+      }
+      throw new Error(res.data.error || '验证失败');
+    },
     async fetchMe() {
       try {
         const res = await http.get<ApiResponse<UserPayload>>('/api/me');
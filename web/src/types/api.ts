@@ -9,7 +9,9 @@ export interface Account {
   name: string;
   wechat_id: string;
   alias?: string;
+  group?: string;
   status: string;
+  unread_count?: number;
   last_task_id?: number | null;
   created_at: string;
   updated_at: string;
@@ -60,5 +62,16 @@ export interface Article {
   content_html: string;
   raw_url: string;
   published_at: string;
+  content_status: string;
+  removed_at?: string | null;
   created_at: string;
+  state?: ArticleState;
+}
+
+export interface ArticleState {
+  read: boolean;
+  starred: boolean;
+  archived: boolean;
+  tags: string[];
+  note: string;
 }